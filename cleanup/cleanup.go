@@ -0,0 +1,146 @@
+// Package cleanup deletes the users and devices a load test run created,
+// through the server's loadtest admin endpoints.
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// IDPrefix is the prefix every loadtest device ID and email starts with.
+// Anything else is refused so a bad input file can't delete real accounts.
+const IDPrefix = "loadtest-"
+
+// Target is one device and the user that was created alongside it.
+// Either field may be empty.
+type Target struct {
+	DeviceID string
+	Email    string
+}
+
+// Result is the outcome of deleting a single record.
+type Result struct {
+	Kind   string // "device" or "user"
+	ID     string
+	Status int // HTTP status, 0 if the request was not sent
+	Err    string
+	DryRun bool
+}
+
+// OK reports whether the record is gone (or would be, for a dry run).
+func (r Result) OK() bool {
+	return r.Err == ""
+}
+
+// Client talks to the loadtest admin endpoints.
+type Client struct {
+	base      string
+	secretKey string
+	client    *http.Client
+}
+
+// New creates a cleanup client for the given server.
+func New(serverURL, secretKey string) *Client {
+	return &Client{
+		base:      strings.TrimRight(serverURL, "/"),
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Run deletes every target's device and then its user. With dryRun set no
+// requests are sent and each result records what would have been deleted.
+func (c *Client) Run(targets []Target, dryRun bool) []Result {
+	var results []Result
+	for _, t := range targets {
+		if t.DeviceID != "" {
+			results = append(results, c.delete("device", t.DeviceID, "/loadtest/device/", dryRun))
+		}
+		if t.Email != "" {
+			results = append(results, c.delete("user", t.Email, "/loadtest/user/", dryRun))
+		}
+	}
+	return results
+}
+
+func (c *Client) delete(kind, id, prefix string, dryRun bool) Result {
+	res := Result{Kind: kind, ID: id, DryRun: dryRun}
+	if !strings.HasPrefix(id, IDPrefix) {
+		res.Err = fmt.Sprintf("refusing to delete %s without %q prefix", kind, IDPrefix)
+		return res
+	}
+	if dryRun {
+		return res
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.base+prefix+url.PathEscape(id), nil)
+	if err != nil {
+		res.Err = err.Error()
+		return res
+	}
+	req.Header.Set("X-Loadtest-Key", c.secretKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		res.Err = err.Error()
+		return res
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	res.Status = resp.StatusCode
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+	case resp.StatusCode == http.StatusNotFound:
+		// Already gone — the goal is reached.
+	default:
+		res.Err = fmt.Sprintf("delete returned %d", resp.StatusCode)
+	}
+	return res
+}
+
+// WriteSummary writes one line per record followed by a totals line.
+func WriteSummary(w io.Writer, results []Result) {
+	fmt.Fprintln(w, "\n--- Cleanup ---")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tID\tSTATUS\tRESULT")
+	failed := 0
+	for _, r := range results {
+		status := "-"
+		if r.Status != 0 {
+			status = fmt.Sprintf("%d", r.Status)
+		}
+		outcome := "deleted"
+		switch {
+		case !r.OK():
+			outcome = "FAILED: " + r.Err
+			failed++
+		case r.DryRun:
+			outcome = "would delete"
+		case r.Status == http.StatusNotFound:
+			outcome = "already gone"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Kind, r.ID, status, outcome)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d records, %d failed\n", len(results), failed)
+}
+
+// Failed returns the number of results that did not succeed.
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if !r.OK() {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/commute-live/loadtest/cleanup"
+	"github.com/commute-live/loadtest/device"
+	"github.com/commute-live/loadtest/manifest"
+	"github.com/commute-live/loadtest/runner"
+	"github.com/spf13/cobra"
+)
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Delete the devices and users a previous run created",
+	Long: `Deletes exactly the records listed in a run manifest or report (or given
+by flag) through the server's loadtest endpoints, authenticated with
+LOADTEST_SECRET_KEY.`,
+	RunE: runCleanup,
+}
+
+var (
+	flagCleanupReport   string
+	flagCleanupManifest string
+	flagCleanupDevices  []string
+	flagCleanupEmails   []string
+	flagCleanupDryRun   bool
+)
+
+func init() {
+	f := cleanupCmd.Flags()
+	f.StringVar(&flagCleanupManifest, "manifest", "", "Run manifest listing the records to delete")
+	f.StringVar(&flagCleanupReport, "report", "", "JSON report from a headless run listing the records to delete")
+	f.StringSliceVar(&flagCleanupDevices, "device-id", nil, "Device ID to delete (repeatable)")
+	f.StringSliceVar(&flagCleanupEmails, "email", nil, "User email to delete (repeatable)")
+	f.BoolVar(&flagCleanupDryRun, "dry-run", false, "List what would be deleted without sending requests")
+	rootCmd.AddCommand(cleanupCmd)
+}
+
+func runCleanup(cmd *cobra.Command, args []string) error {
+	serverURL := os.Getenv("LOADTEST_SERVER_URL")
+	if serverURL == "" {
+		return fmt.Errorf("LOADTEST_SERVER_URL is required but not set")
+	}
+	secretKey := os.Getenv("LOADTEST_SECRET_KEY")
+	if secretKey == "" {
+		return fmt.Errorf("LOADTEST_SECRET_KEY is required but not set")
+	}
+
+	var targets []cleanup.Target
+	if flagCleanupManifest != "" {
+		m, err := manifest.Load(flagCleanupManifest)
+		if err != nil {
+			return err
+		}
+		for _, d := range m.Devices {
+			targets = append(targets, cleanup.Target{DeviceID: d.DeviceID, Email: d.Email})
+		}
+	}
+	if flagCleanupReport != "" {
+		t, err := targetsFromReport(flagCleanupReport)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, t...)
+	}
+	for _, id := range flagCleanupDevices {
+		targets = append(targets, cleanup.Target{DeviceID: id})
+	}
+	for _, email := range flagCleanupEmails {
+		targets = append(targets, cleanup.Target{Email: email})
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("nothing to clean up: pass --manifest, --report, --device-id or --email")
+	}
+
+	return runCleanupTargets(serverURL, secretKey, targets, flagCleanupDryRun)
+}
+
+// runCleanupTargets deletes targets, prints the summary to stderr and fails
+// if any record could not be deleted.
+func runCleanupTargets(serverURL, secretKey string, targets []cleanup.Target, dryRun bool) error {
+	results := cleanup.New(serverURL, secretKey).Run(targets, dryRun)
+	cleanup.WriteSummary(os.Stderr, results)
+	if n := cleanup.Failed(results); n > 0 {
+		return fmt.Errorf("cleanup: %d of %d records failed", n, len(results))
+	}
+	return nil
+}
+
+func targetsFromReport(path string) ([]cleanup.Target, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read report: %w", err)
+	}
+	var rep runner.Report
+	if err := json.Unmarshal(b, &rep); err != nil {
+		return nil, fmt.Errorf("parse report %s: %w", path, err)
+	}
+	targets := make([]cleanup.Target, 0, len(rep.Devices))
+	for _, d := range rep.Devices {
+		targets = append(targets, cleanup.Target{DeviceID: d.DeviceID, Email: d.Email})
+	}
+	return targets, nil
+}
+
+// targetsFromRunner lists this run's records. Devices a ramp never launched
+// created nothing and are skipped.
+func targetsFromRunner(r *runner.Runner) []cleanup.Target {
+	targets := make([]cleanup.Target, 0, len(r.Devices))
+	for _, d := range r.Devices {
+		if d.GetState() == device.StateInit {
+			continue
+		}
+		targets = append(targets, cleanup.Target{DeviceID: d.DeviceID, Email: d.Email})
+	}
+	return targets
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/commute-live/loadtest/runner"
+)
+
+// runHeadless drives the runner without a TUI. Progress lines go to stderr and
+// the final JSON report goes to reportPath ("-" for stdout).
+func runHeadless(r *runner.Runner, duration, interval time.Duration, reportPath string) error {
+	var deviceWg sync.WaitGroup
+	r.Start(&deviceWg, nil)
+	r.WatchSignals(nil, duration)
+
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case <-r.StopCh:
+			break loop
+		case <-ticker.C:
+			printProgress(r.Stats)
+			// Without a duration nobody would stop a run whose devices all failed.
+			if r.Finished() {
+				break loop
+			}
+		}
+	}
+
+	r.Shutdown()
+	deviceWg.Wait()
+	r.Settle()
+	printProgress(r.Stats)
+
+	return writeReport(r, reportPath)
+}
+
+func printProgress(s *runner.Stats) {
+	snap := s.Snapshot()
+	fmt.Fprintf(os.Stderr, "[%7.1fs] devices=%d/%d active=%d errors=%d retries=%d mqtt=%d rate=%.1f/s drops=%d reconnects=%d bad_payloads=%d\n",
+		snap.ElapsedSec, snap.Launched, snap.TotalDevices, snap.ActiveDevices, snap.ErrorCount, snap.Retries, snap.MQTTTotal, snap.MsgsPerSec,
+		snap.MQTTDrops, snap.MQTTReconnects, snap.PayloadErrors)
+}
+
+func writeReport(r *runner.Runner, path string) error {
+	if path == "" || path == "-" {
+		return r.WriteReport(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create report: %w", err)
+	}
+	defer f.Close()
+	if err := r.WriteReport(f); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Report written to %s\n", path)
+	return nil
+}
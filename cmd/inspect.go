@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/commute-live/loadtest/manifest"
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <manifest>",
+	Short: "Show the run and device identities recorded in a run manifest",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runInspect,
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+}
+
+func runInspect(cmd *cobra.Command, args []string) error {
+	m, err := manifest.Load(args[0])
+	if err != nil {
+		return err
+	}
+
+	finished := "no (crashed or still running)"
+	if !m.FinishedAt.IsZero() {
+		finished = m.FinishedAt.Format(time.RFC3339)
+	}
+	fmt.Printf("Run:      %s\n", m.RunID)
+	fmt.Printf("Server:   %s\n", m.ServerURL)
+	fmt.Printf("Started:  %s\n", m.StartedAt.Format(time.RFC3339))
+	fmt.Printf("Finished: %s\n", finished)
+	fmt.Printf("Devices:  %d\n\n", len(m.Devices))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEVICE ID\tEMAIL\tPROVIDER\tLINE\tSTOP\tDIR")
+	for _, d := range m.Devices {
+		id, email := d.DeviceID, d.Email
+		for _, s := range d.Stops() {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				id, email, s.ProviderID, s.Line, s.StopID, s.Direction)
+			id, email = "", "" // further lines of the same device
+		}
+	}
+	return tw.Flush()
+}
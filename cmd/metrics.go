@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/commute-live/loadtest/runner"
+)
+
+var flagMetricsAddr string
+
+func init() {
+	rootCmd.Flags().StringVar(&flagMetricsAddr, "metrics-addr", "", `Serve Prometheus metrics at http://<addr>/metrics during the run, e.g. ":9100"`)
+}
+
+// startMetrics serves r's metrics on addr until the returned stop func is
+// called. Listening happens up front so a taken port fails the run early.
+func startMetrics(r *runner.Runner, addr string) (stop func(), err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r.MetricsHandler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintln(os.Stderr, "metrics server:", err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "Metrics: http://%s/metrics\n", ln.Addr())
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
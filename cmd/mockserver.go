@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/commute-live/loadtest/mockserver"
+	"github.com/spf13/cobra"
+)
+
+var mockServerCmd = &cobra.Command{
+	Use:   "mockserver",
+	Short: "Run a fake CommuteLive backend (HTTP API + MQTT broker) for offline self-tests",
+	Long: `Runs an in-process fake of the CommuteLive HTTP API and MQTT broker.
+
+Point a load test at it with:
+  LOADTEST_SERVER_URL=http://localhost:8080 LOADTEST_MQTT_HOST=localhost loadtest --force`,
+	RunE: runMockServer,
+}
+
+var (
+	mockCfg     mockserver.Config
+	mockTLSCert string
+	mockTLSKey  string
+)
+
+func init() {
+	f := mockServerCmd.Flags()
+	f.StringVar(&mockCfg.HTTPAddr, "http-addr", ":8080", "HTTP API listen address")
+	f.StringVar(&mockCfg.MQTTAddr, "mqtt-addr", ":1883", "MQTT broker listen address")
+	f.StringVar(&mockCfg.MQTTWSAddr, "mqtt-ws-addr", "", `MQTT over websockets listen address, e.g. ":8083" (path "/mqtt")`)
+	f.BoolVar(&mockCfg.RequireDeviceAuth, "require-device-auth", false, "Reject the shared MQTT login; devices must use per-device credentials")
+	f.StringVar(&mockTLSCert, "tls-cert", "", "Certificate to serve MQTT over TLS (ssl:// and wss://)")
+	f.StringVar(&mockTLSKey, "tls-key", "", "Key for --tls-cert")
+	f.DurationVar(&mockCfg.Latency, "latency", 0, `Latency added to every HTTP response, e.g. "50ms"`)
+	f.DurationVar(&mockCfg.Jitter, "jitter", 0, "Random extra HTTP latency, up to this value")
+	f.Float64Var(&mockCfg.ErrorRate, "error-rate", 0, "Fraction of HTTP requests answered with 503 (0..1)")
+	f.DurationVar(&mockCfg.PushInterval, "push-interval", 0, `Publish arrivals to every configured device at this interval (default: only on config/refresh)`)
+	f.BoolVar(&mockCfg.NoTimestamps, "no-timestamps", false, "Leave sentAt out of arrival pushes, so devices measure latency from their own requests")
+	rootCmd.AddCommand(mockServerCmd)
+}
+
+func runMockServer(cmd *cobra.Command, args []string) error {
+	if mockCfg.ErrorRate < 0 || mockCfg.ErrorRate > 1 {
+		return fmt.Errorf("--error-rate must be between 0 and 1, got %v", mockCfg.ErrorRate)
+	}
+
+	if (mockTLSCert == "") != (mockTLSKey == "") {
+		return fmt.Errorf("--tls-cert and --tls-key must be given together")
+	}
+	if mockTLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(mockTLSCert, mockTLSKey)
+		if err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+		mockCfg.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	// Use the same env vars as the load test so one .env drives both.
+	mockCfg.SecretKey = os.Getenv("LOADTEST_SECRET_KEY")
+	mockCfg.MQTTUsername = "commutelive"
+	if v := os.Getenv("LOADTEST_MQTT_USERNAME"); v != "" {
+		mockCfg.MQTTUsername = v
+	}
+	mockCfg.MQTTPassword = "commutelive"
+	if v := os.Getenv("LOADTEST_MQTT_PASSWORD"); v != "" {
+		mockCfg.MQTTPassword = v
+	}
+	mockCfg.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	srv := mockserver.New(mockCfg)
+	if err := srv.Start(); err != nil {
+		return err
+	}
+	host, port := srv.MQTTAddr()
+	scheme := "tcp"
+	if mockCfg.TLS != nil {
+		scheme = "ssl"
+	}
+	fmt.Fprintf(os.Stderr, "Mock server listening: HTTP %s  MQTT %s://%s:%d\n", srv.HTTPURL(), scheme, host, port)
+	if mockCfg.MQTTWSAddr != "" {
+		fmt.Fprintf(os.Stderr, "MQTT over websockets on %s\n", mockCfg.MQTTWSAddr)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+	fmt.Fprintln(os.Stderr, "Shutting down mock server...")
+	return srv.Close()
+}
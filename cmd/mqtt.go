@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/commute-live/loadtest/device"
+	"github.com/commute-live/loadtest/scenario"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagMQTTTransport    string
+	flagMQTTPath         string
+	flagMQTTCA           string
+	flagMQTTCert         string
+	flagMQTTKey          string
+	flagMQTTServerName   string
+	flagMQTTInsecure     bool
+	flagMQTTAuth         string
+	flagCheckIsolation   bool
+	flagValidatePayloads bool
+	flagProbeDevices     int
+)
+
+func init() {
+	f := rootCmd.Flags()
+	f.StringVar(&flagMQTTTransport, "mqtt-transport", "", "MQTT transport: tcp, ssl, ws or wss (env LOADTEST_MQTT_TRANSPORT; default tcp)")
+	f.StringVar(&flagMQTTPath, "mqtt-path", "", `Websocket path for ws/wss (env LOADTEST_MQTT_PATH; default "/mqtt")`)
+	f.StringVar(&flagMQTTCA, "mqtt-ca", "", "PEM CA bundle to verify the broker (env LOADTEST_MQTT_CA_FILE; default system roots)")
+	f.StringVar(&flagMQTTCert, "mqtt-cert", "", "Client certificate for mutual TLS (env LOADTEST_MQTT_CERT_FILE)")
+	f.StringVar(&flagMQTTKey, "mqtt-key", "", "Client certificate key for mutual TLS (env LOADTEST_MQTT_KEY_FILE)")
+	f.StringVar(&flagMQTTServerName, "mqtt-server-name", "", "TLS server name (SNI) if it differs from the MQTT host (env LOADTEST_MQTT_SERVER_NAME)")
+	f.BoolVar(&flagMQTTInsecure, "mqtt-insecure", false, "Skip broker certificate verification (env LOADTEST_MQTT_INSECURE)")
+	f.StringVar(&flagMQTTAuth, "mqtt-auth", "", "Broker credentials: shared (LOADTEST_MQTT_USERNAME/PASSWORD), server (fetched per device after linking)\nor derived (device ID + HMAC of LOADTEST_MQTT_PASSWORD) (env LOADTEST_MQTT_AUTH; default shared)")
+	f.IntVar(&flagProbeDevices, "probe-devices", 0, "Devices that also try to read and write other devices' topics; any leak fails the run")
+	f.BoolVar(&flagValidatePayloads, "validate-payloads", false, "Check every commands message against the arrival board schema and the device's configured stops, counting mismatches as device failures")
+	f.BoolVar(&flagCheckIsolation, "check-isolation", false, "After subscribing, verify each device cannot subscribe to another device's commands topic")
+}
+
+// resolveMQTTAuth returns the broker auth mode from --mqtt-auth, then
+// LOADTEST_MQTT_AUTH, then the scenario.
+func resolveMQTTAuth(cmd *cobra.Command, sc *scenario.Scenario) (string, error) {
+	mode := flagMQTTAuth
+	if !cmd.Flags().Changed("mqtt-auth") {
+		mode = envOr("LOADTEST_MQTT_AUTH", sc.MQTT.Auth)
+	}
+	switch mode {
+	case "":
+		return device.MQTTAuthShared, nil
+	case device.MQTTAuthShared, device.MQTTAuthServer, device.MQTTAuthDerived:
+		return mode, nil
+	}
+	return "", fmt.Errorf("--mqtt-auth must be shared, server or derived, got %q", mode)
+}
+
+// mqttTransport is the resolved broker transport.
+type mqttTransport struct {
+	name string
+	path string
+	tls  device.TLSOptions
+}
+
+// resolveMQTTTransport takes each transport setting from its flag, then its
+// env var, then the scenario.
+func resolveMQTTTransport(cmd *cobra.Command, sc *scenario.Scenario) (mqttTransport, error) {
+	f := cmd.Flags()
+	pick := func(flag, value, env, fallback string) string {
+		if f.Changed(flag) {
+			return value
+		}
+		return envOr(env, fallback)
+	}
+	t := mqttTransport{
+		name: pick("mqtt-transport", flagMQTTTransport, "LOADTEST_MQTT_TRANSPORT", sc.MQTT.Transport),
+		path: pick("mqtt-path", flagMQTTPath, "LOADTEST_MQTT_PATH", sc.MQTT.Path),
+		tls: device.TLSOptions{
+			CAFile:             pick("mqtt-ca", flagMQTTCA, "LOADTEST_MQTT_CA_FILE", sc.MQTT.TLS.CAFile),
+			CertFile:           pick("mqtt-cert", flagMQTTCert, "LOADTEST_MQTT_CERT_FILE", sc.MQTT.TLS.CertFile),
+			KeyFile:            pick("mqtt-key", flagMQTTKey, "LOADTEST_MQTT_KEY_FILE", sc.MQTT.TLS.KeyFile),
+			ServerName:         pick("mqtt-server-name", flagMQTTServerName, "LOADTEST_MQTT_SERVER_NAME", sc.MQTT.TLS.ServerName),
+			InsecureSkipVerify: sc.MQTT.TLS.InsecureSkipVerify,
+		},
+	}
+	if t.name == "" {
+		t.name = device.TransportTCP
+	}
+	if v := os.Getenv("LOADTEST_MQTT_INSECURE"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return t, fmt.Errorf("invalid LOADTEST_MQTT_INSECURE: %w", err)
+		}
+		t.tls.InsecureSkipVerify = b
+	}
+	if f.Changed("mqtt-insecure") {
+		t.tls.InsecureSkipVerify = flagMQTTInsecure
+	}
+
+	switch t.name {
+	case device.TransportTCP, device.TransportSSL, device.TransportWS, device.TransportWSS:
+	default:
+		return t, fmt.Errorf("--mqtt-transport must be tcp, ssl, ws or wss, got %q", t.name)
+	}
+	if t.tls.InsecureSkipVerify && (t.name == device.TransportSSL || t.name == device.TransportWSS) {
+		fmt.Fprintln(os.Stderr, "WARNING: MQTT broker certificate verification is disabled.")
+	}
+	return t, nil
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/commute-live/loadtest/device"
+	"github.com/commute-live/loadtest/scenario"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagRetryAttempts   int
+	flagRetryBackoff    time.Duration
+	flagRetryMaxBackoff time.Duration
+	flagRetryJitter     float64
+	flagRetryStatus     []int
+	flagStepAttempts    map[string]int
+)
+
+func init() {
+	def := device.DefaultRetryPolicy
+	f := rootCmd.Flags()
+	f.IntVar(&flagRetryAttempts, "retry-attempts", def.MaxAttempts, "Attempts per lifecycle step, including the first (1 = no retries)")
+	f.DurationVar(&flagRetryBackoff, "retry-backoff", def.Backoff, "Delay before the first retry; doubles on each further retry")
+	f.DurationVar(&flagRetryMaxBackoff, "retry-max-backoff", def.MaxBackoff, "Upper bound on the retry delay")
+	f.Float64Var(&flagRetryJitter, "retry-jitter", def.Jitter, "Fraction of each retry delay that is randomised, 0..1")
+	f.IntSliceVar(&flagRetryStatus, "retry-status", def.RetryStatus, "HTTP statuses that are retried; transport errors always are")
+	f.StringToIntVar(&flagStepAttempts, "step-attempts", nil, "Per-step attempts as step=n overriding --retry-attempts, e.g. login=5,set-config=1")
+}
+
+// resolveRetry builds the retry policy from the defaults, then the
+// scenario, then any retry flag given on the command line.
+func resolveRetry(cmd *cobra.Command, sc *scenario.Scenario) (device.RetryPolicy, map[string]device.RetryPolicy, error) {
+	p := applyRetry(device.DefaultRetryPolicy, sc.Retry.RetryPolicy)
+	f := cmd.Flags()
+	if f.Changed("retry-attempts") {
+		p.MaxAttempts = flagRetryAttempts
+	}
+	if f.Changed("retry-backoff") {
+		p.Backoff = flagRetryBackoff
+	}
+	if f.Changed("retry-max-backoff") {
+		p.MaxBackoff = flagRetryMaxBackoff
+	}
+	if f.Changed("retry-jitter") {
+		p.Jitter = flagRetryJitter
+	}
+	if f.Changed("retry-status") {
+		p.RetryStatus = flagRetryStatus
+	}
+	if err := p.Validate(); err != nil {
+		return p, nil, err
+	}
+
+	steps := make(map[string]device.RetryPolicy)
+	for key, sp := range map[string]*scenario.RetryPolicy{
+		"register":         sc.Retry.Steps.Register,
+		"login":            sc.Retry.Steps.Login,
+		"link":             sc.Retry.Steps.Link,
+		"set-config":       sc.Retry.Steps.SetConfig,
+		"get-config":       sc.Retry.Steps.GetConfig,
+		"mqtt-credentials": sc.Retry.Steps.MQTTCreds,
+		"mqtt-connect":     sc.Retry.Steps.MQTTConnect,
+		"mqtt-subscribe":   sc.Retry.Steps.MQTTSubscribe,
+	} {
+		if sp != nil {
+			steps[key] = applyRetry(device.RetryPolicy{}, *sp)
+		}
+	}
+	keys := make([]string, 0, len(flagStepAttempts))
+	for k := range flagStepAttempts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !slices.Contains(device.StepKeys, k) {
+			return p, nil, fmt.Errorf("--step-attempts: unknown step %q", k)
+		}
+		if flagStepAttempts[k] < 1 {
+			return p, nil, fmt.Errorf("--step-attempts: %s must be at least 1, got %d", k, flagStepAttempts[k])
+		}
+		sp := steps[k]
+		sp.MaxAttempts = flagStepAttempts[k]
+		steps[k] = sp
+	}
+	return p, steps, nil
+}
+
+// applyRetry overlays the fields set in the scenario policy onto p.
+func applyRetry(p device.RetryPolicy, sp scenario.RetryPolicy) device.RetryPolicy {
+	if sp.Attempts > 0 {
+		p.MaxAttempts = sp.Attempts
+	}
+	if sp.Backoff > 0 {
+		p.Backoff = time.Duration(sp.Backoff)
+	}
+	if sp.MaxBackoff > 0 {
+		p.MaxBackoff = time.Duration(sp.MaxBackoff)
+	}
+	if sp.Jitter != nil {
+		p.Jitter, p.JitterSet = *sp.Jitter, true
+	}
+	if sp.Statuses != nil {
+		p.RetryStatus = sp.Statuses
+	}
+	return p
+}
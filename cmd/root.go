@@ -1,14 +1,20 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/commute-live/loadtest/device"
+	"github.com/commute-live/loadtest/manifest"
+	"github.com/commute-live/loadtest/providers"
 	"github.com/commute-live/loadtest/runner"
 	"github.com/commute-live/loadtest/tui"
 	"github.com/spf13/cobra"
@@ -21,36 +27,83 @@ var rootCmd = &cobra.Command{
 }
 
 var (
-	flagDevices   int
-	flagProviders string
-	flagDuration  string
-	flagForce     bool
-	flagNoMenu    bool
+	flagDevices    int
+	flagProviders  string
+	flagProvCounts string
+	flagDuration   string
+	flagForce      bool
+	flagNoMenu     bool
+	flagHeadless   bool
+	flagReport     string
+	flagProgress   time.Duration
+	flagRampStep   int
+	flagRampEvery  time.Duration
+	flagRampOver   time.Duration
+	flagCleanup    string
+	flagCleanupDry bool
+	flagManifest   string
+	flagReuse      string
+	flagSkipLink   bool
+	flagSkipConfig bool
+
+	flagCaptureBodies bool
+	flagCaptureLimit  int
+
+	flagSeed int64
 )
 
 func init() {
 	rootCmd.Flags().IntVar(&flagDevices, "devices", 5, "Default number of devices shown in setup menu")
-	rootCmd.Flags().StringVar(&flagProviders, "providers", "cta=25,mta=25,mbta=25,septa=25", `Provider distribution, e.g. "cta=30,mta=40,mbta=20,septa=10" (must sum to 100)`)
+	rootCmd.Flags().StringVar(&flagProviders, "providers", "", `Provider distribution, e.g. "cta=30,mta=40,mbta=20,septa=10" (must sum to 100; default: equal across the stop catalogue)`)
+	rootCmd.Flags().StringVar(&flagProvCounts, "provider-counts", "", `Devices per provider instead of --providers, e.g. "cta=10,mta=3"; the total sets --devices`)
 	rootCmd.Flags().StringVar(&flagDuration, "duration", "", `Default duration shown in setup menu, e.g. "5m" (default: unlimited)`)
 	rootCmd.Flags().BoolVar(&flagForce, "force", false, "Skip staging URL safety check")
 	rootCmd.Flags().BoolVar(&flagNoMenu, "no-menu", false, "Skip interactive setup menu and use flags directly")
+	rootCmd.Flags().BoolVar(&flagHeadless, "headless", false, "Run without a TUI (implies --no-menu); progress goes to stderr")
+	rootCmd.Flags().StringVar(&flagReport, "report", "-", `Path for the JSON report written at the end of a headless run ("-" = stdout)`)
+	rootCmd.Flags().IntVar(&flagRampStep, "ramp-step", 0, "Devices to add per ramp step (use with --ramp-interval)")
+	rootCmd.Flags().DurationVar(&flagRampEvery, "ramp-interval", 0, `Pause between ramp steps, e.g. "10s"`)
+	rootCmd.Flags().DurationVar(&flagRampOver, "ramp-over", 0, `Spread device launches linearly over this duration, e.g. "2m"`)
+	rootCmd.Flags().StringVar(&flagCleanup, "cleanup", "sql", `End-of-run cleanup: "api" deletes this run's records, "sql" prints SQL, "none" skips`)
+	rootCmd.Flags().BoolVar(&flagCleanupDry, "cleanup-dry-run", false, "With --cleanup=api, list what would be deleted without deleting")
+	rootCmd.Flags().StringVar(&flagManifest, "manifest-dir", ".loadtest/runs", `Directory for run manifests of created identities ("" disables)`)
+	rootCmd.Flags().StringVar(&flagReuse, "reuse", "", "Run manifest whose identities to reuse; devices skip registration and start at login")
+	rootCmd.Flags().BoolVar(&flagSkipLink, "skip-link", false, "With --reuse, skip linking the device to its user")
+	rootCmd.Flags().BoolVar(&flagSkipConfig, "skip-config", false, "With --reuse, skip the config POST (config is still fetched)")
+	rootCmd.Flags().BoolVar(&flagCaptureBodies, "capture-bodies", false, fmt.Sprintf("Keep HTTP headers and bodies of each device's newest %d requests in its log, with secrets redacted", device.CapturedEntries))
+	rootCmd.Flags().IntVar(&flagCaptureLimit, "capture-limit", device.DefaultCaptureLimit, "With --capture-bodies, bytes of each body to keep")
+	rootCmd.Flags().Int64Var(&flagSeed, "seed", 0, "Seed for provider assignment, stop selection and retry jitter (0 = random; the seed used is printed). Device IDs and passwords are always random")
+	rootCmd.Flags().DurationVar(&flagProgress, "progress-interval", 10*time.Second, "Interval between progress lines in headless mode")
 }
 
 // Execute is the entry point called from main.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
 
 func runLoadTest(cmd *cobra.Command, args []string) error {
-	serverURL := os.Getenv("LOADTEST_SERVER_URL")
+	sc, err := loadScenario()
+	if err != nil {
+		return err
+	}
+	applyScenario(cmd, sc)
+	if err := loadStops(cmd, sc); err != nil {
+		return err
+	}
+
+	serverURL := envOr("LOADTEST_SERVER_URL", sc.Server.URL)
 	if serverURL == "" {
 		return fmt.Errorf("LOADTEST_SERVER_URL is required but not set")
 	}
 
-	secretKey := os.Getenv("LOADTEST_SECRET_KEY")
+	secretKey := envOr("LOADTEST_SECRET_KEY", sc.Server.SecretKey)
 	if secretKey == "" {
 		return fmt.Errorf("LOADTEST_SECRET_KEY is required but not set")
 	}
@@ -61,12 +114,39 @@ func runLoadTest(cmd *cobra.Command, args []string) error {
 		os.Exit(1)
 	}
 
-	mqttHost := os.Getenv("LOADTEST_MQTT_HOST")
+	mqttHost := envOr("LOADTEST_MQTT_HOST", sc.MQTT.Host)
 	if mqttHost == "" {
 		return fmt.Errorf("LOADTEST_MQTT_HOST is required but not set")
 	}
 
-	mqttPort := 1883
+	transport, err := resolveMQTTTransport(cmd, sc)
+	if err != nil {
+		return err
+	}
+	mqttAuth, err := resolveMQTTAuth(cmd, sc)
+	if err != nil {
+		return err
+	}
+	checkIsolation := flagCheckIsolation || (!cmd.Flags().Changed("check-isolation") && sc.MQTT.CheckIsolation)
+	validatePayloads := flagValidatePayloads || (!cmd.Flags().Changed("validate-payloads") && sc.MQTT.ValidatePayloads)
+	captureBodies := flagCaptureBodies || (!cmd.Flags().Changed("capture-bodies") && sc.Server.CaptureBodies)
+	captureLimit := flagCaptureLimit
+	if !cmd.Flags().Changed("capture-limit") && sc.Server.CaptureLimit != 0 {
+		captureLimit = sc.Server.CaptureLimit
+	}
+	seed := flagSeed
+	if !cmd.Flags().Changed("seed") {
+		seed = sc.Seed
+	}
+	probes := flagProbeDevices
+	if !cmd.Flags().Changed("probe-devices") {
+		probes = sc.MQTT.ProbeDevices
+	}
+
+	mqttPort := device.DefaultMQTTPort(transport.name)
+	if sc.MQTT.Port != 0 {
+		mqttPort = sc.MQTT.Port
+	}
 	if v := os.Getenv("LOADTEST_MQTT_PORT"); v != "" {
 		p, err := strconv.Atoi(v)
 		if err != nil {
@@ -75,27 +155,95 @@ func runLoadTest(cmd *cobra.Command, args []string) error {
 		mqttPort = p
 	}
 
-	mqttUsername := "commutelive"
-	if v := os.Getenv("LOADTEST_MQTT_USERNAME"); v != "" {
-		mqttUsername = v
+	mqttUsername := envOr("LOADTEST_MQTT_USERNAME", sc.MQTT.Username)
+	if mqttUsername == "" {
+		mqttUsername = "commutelive"
 	}
 
-	mqttPassword := "commutelive"
-	if v := os.Getenv("LOADTEST_MQTT_PASSWORD"); v != "" {
-		mqttPassword = v
+	mqttPassword := envOr("LOADTEST_MQTT_PASSWORD", sc.MQTT.Password)
+	if mqttPassword == "" {
+		mqttPassword = "commutelive"
 	}
 
 	providerDist, err := parseProviderDist(flagProviders)
 	if err != nil {
 		return err
 	}
+	if flagProvCounts != "" {
+		if flagProviders != "" {
+			return fmt.Errorf("--providers and --provider-counts are mutually exclusive")
+		}
+		counts, err := parseProviderCounts(flagProvCounts)
+		if err != nil {
+			return err
+		}
+		total := distTotal(counts)
+		if (cmd.Flags().Changed("devices") || sc.Devices > 0) && flagDevices != total {
+			return fmt.Errorf("provider counts add up to %d devices, but %d devices were asked for", total, flagDevices)
+		}
+		flagDevices = total
+		providerDist = counts
+	}
+
+	switch flagCleanup {
+	case "api", "sql", "none":
+	default:
+		return fmt.Errorf(`invalid --cleanup %q (expected "api", "sql" or "none")`, flagCleanup)
+	}
+
+	thresholds, err := resolveThresholds(cmd, sc)
+	if err != nil {
+		return err
+	}
+	timeouts, err := resolveTimeouts(sc)
+	if err != nil {
+		return err
+	}
+	retry, stepRetry, err := resolveRetry(cmd, sc)
+	if err != nil {
+		return err
+	}
+
+	var reuse []manifest.Device
+	if flagReuse != "" {
+		m, err := manifest.Load(flagReuse)
+		if err != nil {
+			return err
+		}
+		if len(m.Devices) == 0 {
+			return fmt.Errorf("manifest %s has no device identities", flagReuse)
+		}
+		reuse = m.Devices
+		fmt.Fprintf(os.Stderr, "Reusing %d identities from run %s\n", len(reuse), m.RunID)
+		// Deleting reused identities would defeat the point of keeping them.
+		if !cmd.Flags().Changed("cleanup") {
+			flagCleanup = "none"
+		}
+	} else if flagSkipLink || flagSkipConfig {
+		return fmt.Errorf("--skip-link and --skip-config require --reuse")
+	}
+
+	ramp := runner.Ramp{Step: flagRampStep, Interval: flagRampEvery, Over: flagRampOver}
+	if err := ramp.Validate(); err != nil {
+		return err
+	}
+	lines, err := resolveLines(cmd, sc)
+	if err != nil {
+		return err
+	}
 
 	// ── Step 1: Interactive setup menu ──────────────────────────────────────
 	devices := flagDevices
 	durationStr := flagDuration
 
-	if !flagNoMenu {
-		setupModel := tui.NewSetupModel(serverURL, flagDevices, flagDuration)
+	if !flagNoMenu && !flagHeadless {
+		setupModel := tui.NewSetupModel(serverURL, tui.SetupResult{
+			Devices:   devices,
+			Duration:  durationStr,
+			Providers: providerDist,
+			Counts:    flagProvCounts != "",
+			Ramp:      ramp,
+		})
 		setupP := tea.NewProgram(setupModel, tea.WithAltScreen())
 		finalModel, err := setupP.Run()
 		if err != nil {
@@ -109,6 +257,7 @@ func runLoadTest(cmd *cobra.Command, args []string) error {
 		devices = result.Devices
 		durationStr = result.Duration
 		providerDist = result.Providers
+		ramp = result.Ramp
 	}
 
 	var duration time.Duration
@@ -121,21 +270,66 @@ func runLoadTest(cmd *cobra.Command, args []string) error {
 
 	// ── Step 2: Build runner and start load test ─────────────────────────────
 	cfg := runner.Config{
-		ServerURL:    serverURL,
-		SecretKey:    secretKey,
-		MQTTHost:     mqttHost,
-		MQTTPort:     mqttPort,
-		MQTTUsername: mqttUsername,
-		MQTTPassword: mqttPassword,
-		Devices:      devices,
-		Providers:    providerDist,
-		Duration:     duration,
+		ServerURL:        serverURL,
+		SecretKey:        secretKey,
+		MQTTHost:         mqttHost,
+		MQTTPort:         mqttPort,
+		MQTTUsername:     mqttUsername,
+		MQTTPassword:     mqttPassword,
+		MQTTTransport:    transport.name,
+		MQTTPath:         transport.path,
+		MQTTTLS:          transport.tls,
+		MQTTAuth:         mqttAuth,
+		CheckIsolation:   checkIsolation,
+		ValidatePayloads: validatePayloads,
+		CaptureBodies:    captureBodies,
+		CaptureLimit:     captureLimit,
+		Probes:           probes,
+		Devices:          devices,
+		Providers:        providerDist,
+		Duration:         duration,
+		Ramp:             ramp,
+		Lines:            lines,
+		ManifestDir:      flagManifest,
+		Reuse:            reuse,
+		SkipLink:         flagSkipLink,
+		SkipConfig:       flagSkipConfig,
+		Thresholds:       thresholds,
+		Timeouts:         timeouts,
+		Retry:            retry,
+		StepRetry:        stepRetry,
+		Seed:             seed,
 	}
 
 	r, err := runner.New(cfg)
 	if err != nil {
 		return err
 	}
+	// From here on failures are about the run, not how the CLI was invoked.
+	cmd.SilenceUsage = true
+	fmt.Fprintf(os.Stderr, "Seed %d — pass --seed %d to replay this run\n", r.Seed, r.Seed)
+	if r.Manifest != nil {
+		fmt.Fprintf(os.Stderr, "Run %s — manifest: %s\n", r.RunID, r.Manifest.Path())
+	}
+	if r.ObserverErr != nil {
+		fmt.Fprintln(os.Stderr, "WARNING: isolation probe observer:", r.ObserverErr, "— publish leaks will not be detected.")
+	}
+	if flagMetricsAddr != "" {
+		stop, err := startMetrics(r, flagMetricsAddr)
+		if err != nil {
+			return err
+		}
+		// Stay up through the summary so a final scrape sees the end state.
+		defer stop()
+	}
+
+	if flagHeadless {
+		if err := runHeadless(r, duration, flagProgress, flagReport); err != nil {
+			return err
+		}
+		// stdout may carry the JSON report, so keep the summary off it.
+		return finishRun(r, serverURL, secretKey, os.Stderr)
+	}
 
 	model := tui.NewModel(r.Devices, r.Stats)
 	p := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion())
@@ -153,27 +347,101 @@ func runLoadTest(cmd *cobra.Command, args []string) error {
 	// TUI has exited — stop all devices and wait for them to finish.
 	r.Shutdown()
 	deviceWg.Wait()
-	r.PrintCleanupSQL()
+	return finishRun(r, serverURL, secretKey, os.Stdout)
+}
+
+// finishRun prints the end-of-run summary to w, cleans up according to
+// --cleanup and fails with exitThresholdFailed if any threshold or the
+// isolation check or probe failed.
+func finishRun(r *runner.Runner, serverURL, secretKey string, w io.Writer) error {
+	if err := r.FinishManifest(); err != nil {
+		fmt.Fprintln(os.Stderr, "manifest:", err)
+	}
+	r.Settle()
+	fmt.Fprintf(w, "Seed: %d\n", r.Seed)
+	r.WriteProviderTable(w)
+	r.WriteLatencyTable(w)
+	checks := r.CheckThresholds()
+	runner.WriteThresholdTable(w, checks)
+	security := r.SecurityReport()
+	runner.WriteSecurityTable(w, security)
+
+	switch flagCleanup {
+	case "api":
+		if err := runCleanupTargets(serverURL, secretKey, targetsFromRunner(r), flagCleanupDry); err != nil {
+			return err
+		}
+	case "sql":
+		r.WriteCleanupSQL(w)
+	}
+
+	if !runner.Passed(checks) {
+		return &exitError{code: exitThresholdFailed, err: fmt.Errorf("run failed its thresholds")}
+	}
+	if security != nil && !security.Passed {
+		return &exitError{code: exitThresholdFailed, err: fmt.Errorf("isolation check or probe found cross-device access")}
+	}
 	return nil
 }
 
+// parseProviderDist parses "key=percent,..." against the stop catalogue.
+// An empty string splits evenly across every catalogue provider.
 func parseProviderDist(s string) (map[string]int, error) {
+	if strings.TrimSpace(s) == "" {
+		return providers.EqualDist(providers.ValidProviders()), nil
+	}
+	result, err := parseProviderValues(s)
+	if err != nil {
+		return nil, err
+	}
+	if total := distTotal(result); total != 100 {
+		return nil, fmt.Errorf("provider percentages must sum to 100, got %d (use --provider-counts for device counts)", total)
+	}
+	return result, nil
+}
+
+// parseProviderCounts parses "key=devices,..." against the stop catalogue.
+func parseProviderCounts(s string) (map[string]int, error) {
+	result, err := parseProviderValues(s)
+	if err != nil {
+		return nil, err
+	}
+	if distTotal(result) == 0 {
+		return nil, fmt.Errorf("provider counts %q give no devices", s)
+	}
+	return result, nil
+}
+
+// parseProviderValues parses "key=n,..." with non-negative n, checking
+// each key against the stop catalogue.
+func parseProviderValues(s string) (map[string]int, error) {
+	valid := providers.ValidProviders()
 	result := make(map[string]int)
-	total := 0
 	for _, part := range strings.Split(s, ",") {
 		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
 		if len(kv) != 2 {
 			return nil, fmt.Errorf("invalid provider distribution entry %q (expected key=value)", part)
 		}
-		pct, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		key := strings.TrimSpace(kv[0])
+		if !slices.Contains(valid, key) {
+			return nil, fmt.Errorf("unknown provider %q (the stop catalogue has %s)", key, strings.Join(valid, ", "))
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(kv[1]))
 		if err != nil {
-			return nil, fmt.Errorf("invalid percentage for provider %q: %w", kv[0], err)
+			return nil, fmt.Errorf("invalid value for provider %q: %w", kv[0], err)
 		}
-		result[strings.TrimSpace(kv[0])] = pct
-		total += pct
-	}
-	if total != 100 {
-		return nil, fmt.Errorf("provider percentages must sum to 100, got %d", total)
+		if n < 0 {
+			return nil, fmt.Errorf("value for provider %q must not be negative, got %d", key, n)
+		}
+		result[key] = n
 	}
 	return result, nil
 }
+
+func distTotal(dist map[string]int) int {
+	total := 0
+	for _, n := range dist {
+		total += n
+	}
+	return total
+}
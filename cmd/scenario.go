@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/commute-live/loadtest/scenario"
+	"github.com/spf13/cobra"
+)
+
+var flagScenario string
+
+func init() {
+	rootCmd.Flags().StringVar(&flagScenario, "scenario", "", "YAML or JSON scenario file; env vars and flags override its values")
+}
+
+// loadScenario reads --scenario, or returns an empty scenario if unset.
+func loadScenario() (*scenario.Scenario, error) {
+	if flagScenario == "" {
+		return &scenario.Scenario{}, nil
+	}
+	return scenario.Load(flagScenario)
+}
+
+// applyScenario copies scenario values into the flag variables of every flag
+// the user did not set explicitly.
+func applyScenario(cmd *cobra.Command, sc *scenario.Scenario) {
+	f := cmd.Flags()
+	if !f.Changed("devices") && sc.Devices > 0 {
+		flagDevices = sc.Devices
+	}
+	// The provider flags are one setting; either on the command line
+	// replaces the scenario's distribution entirely.
+	if !f.Changed("providers") && !f.Changed("provider-counts") {
+		if len(sc.Providers) > 0 {
+			flagProviders = formatProviderDist(sc.Providers)
+		}
+		if len(sc.ProviderCounts) > 0 {
+			flagProvCounts = formatProviderDist(sc.ProviderCounts)
+		}
+	}
+	if !f.Changed("duration") && sc.Duration > 0 {
+		flagDuration = formatDuration(time.Duration(sc.Duration))
+	}
+	// The ramp flags are one setting; any of them on the command line
+	// replaces the scenario's ramp entirely.
+	if !f.Changed("ramp-step") && !f.Changed("ramp-interval") && !f.Changed("ramp-over") {
+		flagRampStep = sc.Ramp.Step
+		flagRampEvery = time.Duration(sc.Ramp.Interval)
+		flagRampOver = time.Duration(sc.Ramp.Over)
+	}
+}
+
+// envOr returns the environment variable key, or fallback if it is unset.
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// formatDuration writes d the way the setup menu's presets are written,
+// e.g. "10m" rather than "10m0s", so a scenario duration matches its preset.
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+func formatProviderDist(dist map[string]int) string {
+	keys := make([]string, 0, len(dist))
+	for k := range dist {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, dist[k]))
+	}
+	return strings.Join(parts, ",")
+}
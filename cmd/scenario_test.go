@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/commute-live/loadtest/scenario"
+	"github.com/spf13/cobra"
+)
+
+// precedenceCmd returns a command carrying the flags under test, bound to
+// the same variables as the root command's, with flags set as if given on
+// the command line.
+func precedenceCmd(t *testing.T, flags map[string]string) *cobra.Command {
+	t.Helper()
+	c := &cobra.Command{}
+	f := c.Flags()
+	f.StringVar(&flagMQTTAuth, "mqtt-auth", "", "")
+	f.StringVar(&flagMQTTTransport, "mqtt-transport", "", "")
+	f.StringVar(&flagMQTTPath, "mqtt-path", "", "")
+	f.StringVar(&flagMQTTCA, "mqtt-ca", "", "")
+	f.StringVar(&flagMQTTCert, "mqtt-cert", "", "")
+	f.StringVar(&flagMQTTKey, "mqtt-key", "", "")
+	f.StringVar(&flagMQTTServerName, "mqtt-server-name", "", "")
+	f.BoolVar(&flagMQTTInsecure, "mqtt-insecure", false, "")
+	f.IntVar(&flagDevices, "devices", 5, "")
+	f.StringVar(&flagDuration, "duration", "", "")
+	for name, v := range flags {
+		if err := f.Set(name, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+func TestPrecedence(t *testing.T) {
+	sc := &scenario.Scenario{
+		MQTT: scenario.MQTT{Auth: "server", Path: "/scenario", TLS: scenario.MQTTTLS{InsecureSkipVerify: true}},
+	}
+	tests := []struct {
+		name     string
+		env      map[string]string
+		flags    map[string]string
+		auth     string
+		path     string
+		insecure bool
+	}{
+		{"scenario", nil, nil, "server", "/scenario", true},
+		{"env over scenario",
+			map[string]string{"LOADTEST_MQTT_AUTH": "derived", "LOADTEST_MQTT_PATH": "/env", "LOADTEST_MQTT_INSECURE": "false"},
+			nil, "derived", "/env", false},
+		{"flag over env",
+			map[string]string{"LOADTEST_MQTT_AUTH": "derived", "LOADTEST_MQTT_PATH": "/env", "LOADTEST_MQTT_INSECURE": "false"},
+			map[string]string{"mqtt-auth": "shared", "mqtt-path": "/flag", "mqtt-insecure": "true"},
+			"shared", "/flag", true},
+		{"flag over scenario", nil,
+			map[string]string{"mqtt-auth": "derived", "mqtt-insecure": "false"}, "derived", "/scenario", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"LOADTEST_MQTT_AUTH", "LOADTEST_MQTT_PATH", "LOADTEST_MQTT_INSECURE"} {
+				t.Setenv(k, tt.env[k])
+			}
+			c := precedenceCmd(t, tt.flags)
+			auth, err := resolveMQTTAuth(c, sc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr, err := resolveMQTTTransport(c, sc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if auth != tt.auth || tr.path != tt.path || tr.tls.InsecureSkipVerify != tt.insecure {
+				t.Errorf("got auth %q, path %q, insecure %v; want %q, %q, %v",
+					auth, tr.path, tr.tls.InsecureSkipVerify, tt.auth, tt.path, tt.insecure)
+			}
+		})
+	}
+}
+
+func TestApplyScenario(t *testing.T) {
+	sc := &scenario.Scenario{Devices: 20, Duration: scenario.Duration(10 * time.Minute)}
+
+	applyScenario(precedenceCmd(t, nil), sc)
+	if flagDevices != 20 || flagDuration != "10m" {
+		t.Errorf("scenario: got %d devices for %q, want 20 for 10m", flagDevices, flagDuration)
+	}
+
+	applyScenario(precedenceCmd(t, map[string]string{"devices": "3", "duration": "30s"}), sc)
+	if flagDevices != 3 || flagDuration != "30s" {
+		t.Errorf("flags: got %d devices for %q, want 3 for 30s", flagDevices, flagDuration)
+	}
+}
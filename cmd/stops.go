@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/commute-live/loadtest/gtfs"
+	"github.com/commute-live/loadtest/providers"
+	"github.com/commute-live/loadtest/runner"
+	"github.com/commute-live/loadtest/scenario"
+	"github.com/spf13/cobra"
+)
+
+var stopsCmd = &cobra.Command{
+	Use:   "stops",
+	Short: "Build stop catalogues for --stops",
+}
+
+var importGTFSCmd = &cobra.Command{
+	Use:   "import-gtfs FEED.zip",
+	Short: "Write a stop catalogue from a GTFS static feed",
+	Long: `Reads routes.txt, trips.txt, stop_times.txt and stops.txt from a GTFS zip
+and writes one catalogue entry per line, stop and direction that a trip of
+the selected routes serves. Pass the result to the load test with --stops.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImportGTFS,
+}
+
+var (
+	flagStops        string
+	flagStopsMode    string
+	flagLines        string
+	flagMixProviders bool
+
+	flagGTFSOut         string
+	flagGTFSProvider    string
+	flagGTFSProviderID  string
+	flagGTFSRouteTypes  []int
+	flagGTFSRoutes      []string
+	flagGTFSStations    bool
+	flagGTFSNoDirection bool
+	flagGTFSWeighted    bool
+)
+
+func init() {
+	f := rootCmd.Flags()
+	f.StringVar(&flagStops, "stops", "", "Stop catalogue file (.csv, .json, .yaml) with provider, providerId, line, stopId, direction and optional weight")
+	f.StringVar(&flagStopsMode, "stops-mode", providers.CatalogueMerge, `How --stops combines with the built-in stops: "merge" or "replace"`)
+	f.StringVar(&flagLines, "lines", "1", `Stops each device configures: a count, e.g. "3", or a range drawn per device, e.g. "2-4"`)
+	f.BoolVar(&flagMixProviders, "mix-providers", false, "Let a device's further stops come from any provider in --providers, not only its own")
+
+	f = importGTFSCmd.Flags()
+	f.StringVarP(&flagGTFSOut, "out", "o", "stops.csv", "Catalogue file to write; the extension picks CSV, JSON or YAML")
+	f.StringVar(&flagGTFSProvider, "provider", "", `Provider key the stops are listed under, e.g. "wmata" (required)`)
+	f.StringVar(&flagGTFSProviderID, "provider-id", "", `CommuteLive provider ID devices configure, e.g. "wmata-metro" (required)`)
+	f.IntSliceVar(&flagGTFSRouteTypes, "route-type", nil, "Keep only routes of these GTFS route types, e.g. 1 (subway), 2 (rail), 3 (bus)")
+	f.StringSliceVar(&flagGTFSRoutes, "route", nil, "Keep only these route IDs (repeatable or comma-separated)")
+	f.BoolVar(&flagGTFSStations, "parent-stations", false, "List platforms as their parent station")
+	f.BoolVar(&flagGTFSNoDirection, "no-direction", false, "Leave direction empty instead of using the trip's direction_id")
+	f.BoolVar(&flagGTFSWeighted, "weighted", false, "Weight each stop by its number of scheduled stop times")
+	_ = importGTFSCmd.MarkFlagRequired("provider")
+	_ = importGTFSCmd.MarkFlagRequired("provider-id")
+	stopsCmd.AddCommand(importGTFSCmd)
+	rootCmd.AddCommand(stopsCmd)
+}
+
+func runImportGTFS(cmd *cobra.Command, args []string) error {
+	entries, err := gtfs.Import(args[0], gtfs.Options{
+		Provider:       flagGTFSProvider,
+		ProviderID:     flagGTFSProviderID,
+		RouteTypes:     flagGTFSRouteTypes,
+		RouteIDs:       flagGTFSRoutes,
+		ParentStations: flagGTFSStations,
+		NoDirection:    flagGTFSNoDirection,
+		Weighted:       flagGTFSWeighted,
+	})
+	if err != nil {
+		return err
+	}
+	// Check the result the same way --stops will before writing it.
+	if err := providers.ValidateCatalogue(entries); err != nil {
+		return err
+	}
+	if err := providers.WriteCatalogue(flagGTFSOut, entries); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d stops for provider %q to %s\n", len(entries), flagGTFSProvider, flagGTFSOut)
+	return nil
+}
+
+// loadStops installs the stop catalogue named by --stops or the scenario,
+// if any, so that provider keys can be validated against it.
+func loadStops(cmd *cobra.Command, sc *scenario.Scenario) error {
+	path := flagStops
+	if !cmd.Flags().Changed("stops") {
+		path = sc.Stops.File
+	}
+	mode := flagStopsMode
+	if !cmd.Flags().Changed("stops-mode") && sc.Stops.Mode != "" {
+		mode = sc.Stops.Mode
+	}
+	if mode != providers.CatalogueMerge && mode != providers.CatalogueReplace {
+		return fmt.Errorf("invalid --stops-mode %q (expected %q or %q)", mode, providers.CatalogueMerge, providers.CatalogueReplace)
+	}
+	if path == "" {
+		return nil
+	}
+	entries, err := providers.LoadCatalogue(path)
+	if err != nil {
+		return err
+	}
+	return providers.UseCatalogue(entries, mode)
+}
+
+// resolveLines returns the stops per device from --lines and
+// --mix-providers, or the scenario for either flag left unset.
+func resolveLines(cmd *cobra.Command, sc *scenario.Scenario) (runner.Lines, error) {
+	f := cmd.Flags()
+	lines := runner.Lines{Min: sc.Lines.Min, Max: sc.Lines.Max, MixProviders: sc.Lines.MixProviders}
+	if f.Changed("lines") {
+		lo, hi, ok := strings.Cut(flagLines, "-")
+		if !ok {
+			hi = lo
+		}
+		var err1, err2 error
+		lines.Min, err1 = strconv.Atoi(strings.TrimSpace(lo))
+		lines.Max, err2 = strconv.Atoi(strings.TrimSpace(hi))
+		if err1 != nil || err2 != nil {
+			return lines, fmt.Errorf(`invalid --lines %q (expected a count like "3" or a range like "2-4")`, flagLines)
+		}
+	}
+	if f.Changed("mix-providers") {
+		lines.MixProviders = flagMixProviders
+	}
+	return lines, lines.Validate()
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/commute-live/loadtest/runner"
+	"github.com/commute-live/loadtest/scenario"
+	"github.com/spf13/cobra"
+)
+
+// exitThresholdFailed is the exit code when a run completes but fails one of
+// its thresholds, so pipelines can tell it apart from a crash (exit 1).
+const exitThresholdFailed = 2
+
+// exitError carries a specific process exit code out of a command.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+var (
+	flagMaxErrorRate  float64
+	flagMinActiveRate float64
+	flagMaxHTTPP95    time.Duration
+	flagMinMsgsPerSec float64
+)
+
+func init() {
+	f := rootCmd.Flags()
+	f.Float64Var(&flagMaxErrorRate, "max-error-rate", 0, "Fail the run if more than this fraction of devices end in ERROR, e.g. 0.05")
+	f.Float64Var(&flagMinActiveRate, "min-active-rate", 0, "Fail the run if fewer than this fraction of devices reach ACTIVE, e.g. 0.95")
+	f.DurationVar(&flagMaxHTTPP95, "max-http-p95", 0, `Fail the run if the p95 of all HTTP requests exceeds this, e.g. "2s"`)
+	f.Float64Var(&flagMinMsgsPerSec, "min-msgs-per-sec", 0, "Fail the run if the MQTT msgs/sec, averaged over the run's active time, is below this")
+}
+
+// resolveThresholds starts from the scenario's thresholds and overrides them
+// with any given on the command line.
+func resolveThresholds(cmd *cobra.Command, sc *scenario.Scenario) (runner.Thresholds, error) {
+	t := runner.Thresholds{
+		MaxErrorRate:  sc.Thresholds.MaxErrorRate,
+		MinActiveRate: sc.Thresholds.MinActiveRate,
+		MinMsgsPerSec: sc.Thresholds.MinMsgsPerSec,
+	}
+	if sc.Thresholds.MaxHTTPP95 != nil {
+		p95 := time.Duration(*sc.Thresholds.MaxHTTPP95)
+		t.MaxHTTPP95 = &p95
+	}
+	f := cmd.Flags()
+	if f.Changed("max-error-rate") {
+		if flagMaxErrorRate < 0 || flagMaxErrorRate > 1 {
+			return t, fmt.Errorf("--max-error-rate must be between 0 and 1, got %v", flagMaxErrorRate)
+		}
+		t.MaxErrorRate = &flagMaxErrorRate
+	}
+	if f.Changed("min-active-rate") {
+		if flagMinActiveRate < 0 || flagMinActiveRate > 1 {
+			return t, fmt.Errorf("--min-active-rate must be between 0 and 1, got %v", flagMinActiveRate)
+		}
+		t.MinActiveRate = &flagMinActiveRate
+	}
+	if f.Changed("max-http-p95") {
+		t.MaxHTTPP95 = &flagMaxHTTPP95
+	}
+	if f.Changed("min-msgs-per-sec") {
+		t.MinMsgsPerSec = &flagMinMsgsPerSec
+	}
+	return t, nil
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/commute-live/loadtest/device"
+	"github.com/commute-live/loadtest/runner"
+)
+
+func TestFinishRunExitCode(t *testing.T) {
+	flagCleanup = "none"
+	for _, tc := range []struct {
+		name     string
+		failed   int
+		wantCode int
+	}{
+		{"thresholds met", 0, 0},
+		{"thresholds failed", 1, exitThresholdFailed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			maxErrors := 0.0
+			r, err := runner.New(runner.Config{
+				MQTTHost:   "127.0.0.1",
+				MQTTPort:   1883,
+				Devices:    2,
+				Providers:  map[string]int{"cta": 100},
+				Thresholds: runner.Thresholds{MaxErrorRate: &maxErrors},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, d := range r.Devices {
+				d.State = device.StateDone
+				if i < tc.failed {
+					d.State = device.StateError
+				}
+			}
+			err = finishRun(r, "", "", io.Discard)
+			var exitErr *exitError
+			switch {
+			case tc.wantCode == 0 && err != nil:
+				t.Fatalf("finishRun = %v, want nil", err)
+			case tc.wantCode != 0 && (!errors.As(err, &exitErr) || exitErr.code != tc.wantCode):
+				t.Fatalf("finishRun = %v, want exit code %d", err, tc.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/commute-live/loadtest/device"
+	"github.com/commute-live/loadtest/scenario"
+)
+
+var flagTimeouts map[string]string
+
+func init() {
+	rootCmd.Flags().StringToStringVar(&flagTimeouts, "timeout", nil,
+		"Per-step client timeout as step=duration, repeatable or comma-separated, e.g. login=2s,mqtt-connect=3s\n(steps: "+strings.Join(device.StepKeys, ", ")+")")
+}
+
+// resolveTimeouts starts from the scenario's timeouts and overrides them with
+// any --timeout given on the command line.
+func resolveTimeouts(sc *scenario.Scenario) (device.Timeouts, error) {
+	t := device.Timeouts{
+		Register:      time.Duration(sc.Timeouts.Register),
+		Login:         time.Duration(sc.Timeouts.Login),
+		Link:          time.Duration(sc.Timeouts.Link),
+		SetConfig:     time.Duration(sc.Timeouts.SetConfig),
+		GetConfig:     time.Duration(sc.Timeouts.GetConfig),
+		MQTTCreds:     time.Duration(sc.Timeouts.MQTTCreds),
+		MQTTConnect:   time.Duration(sc.Timeouts.MQTTConnect),
+		MQTTSubscribe: time.Duration(sc.Timeouts.MQTTSubscribe),
+	}
+	steps := make([]string, 0, len(flagTimeouts))
+	for step := range flagTimeouts {
+		steps = append(steps, step)
+	}
+	sort.Strings(steps)
+	for _, step := range steps {
+		d, err := time.ParseDuration(flagTimeouts[step])
+		if err != nil {
+			return t, fmt.Errorf("--timeout %s: invalid duration %q", step, flagTimeouts[step])
+		}
+		if err := t.Set(step, d); err != nil {
+			return t, fmt.Errorf("--timeout: %w", err)
+		}
+	}
+	return t, nil
+}
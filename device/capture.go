@@ -0,0 +1,108 @@
+package device
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+)
+
+// DefaultCaptureLimit is the number of bytes of each request and response
+// body kept when body capture is on and no limit is given.
+const DefaultCaptureLimit = 4096
+
+// CapturedEntries is how many of a device's newest HTTP log entries keep
+// their capture. Older entries drop it, so memory stays bounded however
+// long the run.
+const CapturedEntries = 20
+
+// Redacted replaces secret header values and JSON fields in captured
+// exchanges.
+const Redacted = "[redacted]"
+
+// secretHeaders are replaced wholesale; they carry the load test key or
+// session credentials.
+var secretHeaders = []string{"X-Loadtest-Key", "Authorization", "Cookie", "Set-Cookie"}
+
+// secretField reports whether a JSON object key names a secret, such as
+// "password" or "mqttPassword".
+func secretField(key string) bool {
+    k := strings.ToLower(key)
+    for _, s := range []string{"password", "secret", "token"} {
+        if strings.Contains(k, s) {
+            return true
+        }
+    }
+    return false
+}
+
+// HTTPCapture holds the headers and bodies of one request/response pair,
+// with secrets redacted and bodies cut to the capture limit.
+type HTTPCapture struct {
+    RequestHeaders  http.Header `json:"requestHeaders,omitempty"`
+    RequestBody     string      `json:"requestBody,omitempty"`
+    ResponseHeaders http.Header `json:"responseHeaders,omitempty"`
+    ResponseBody    string      `json:"responseBody,omitempty"`
+    Truncated       bool        `json:"truncated,omitempty"` // a body was longer than the limit
+}
+
+// redactHeaders returns a copy of h with secret values replaced.
+func redactHeaders(h http.Header) http.Header {
+    if len(h) == 0 {
+        return nil
+    }
+    out := h.Clone()
+    for _, name := range secretHeaders {
+        if _, ok := out[name]; ok {
+            out.Set(name, Redacted)
+        }
+    }
+    return out
+}
+
+// redactBody replaces secret fields in a JSON body and cuts the result to
+// limit bytes, reporting whether it had to. Bodies that are not JSON are
+// only cut.
+func redactBody(b []byte, limit int) (string, bool) {
+    if len(b) == 0 {
+        return "", false
+    }
+    var v interface{}
+    if json.Unmarshal(b, &v) == nil {
+        if out, err := json.Marshal(redactValue(v)); err == nil {
+            b = out
+        }
+    }
+    if len(b) > limit {
+        return string(b[:limit]), true
+    }
+    return string(b), false
+}
+
+func redactValue(v interface{}) interface{} {
+    switch t := v.(type) {
+    case map[string]interface{}:
+        for k, e := range t {
+            if secretField(k) {
+                t[k] = Redacted
+            } else {
+                t[k] = redactValue(e)
+            }
+        }
+    case []interface{}:
+        for i, e := range t {
+            t[i] = redactValue(e)
+        }
+    }
+    return v
+}
+
+// captureLimit returns the per-body capture limit, or 0 if capture is off.
+func (o *Options) captureLimit() int {
+    if !o.CaptureBodies {
+        return 0
+    }
+    if o.CaptureLimit > 0 {
+        return o.CaptureLimit
+    }
+    return DefaultCaptureLimit
+}
@@ -0,0 +1,80 @@
+package device
+
+import (
+    "net/http"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestRedactHeaders(t *testing.T) {
+    tests := []struct {
+        name string
+        in   http.Header
+        want http.Header
+    }{
+        {"none", nil, nil},
+        {"plain kept", http.Header{"Content-Type": {"application/json"}}, http.Header{"Content-Type": {"application/json"}}},
+        {"load test key", http.Header{"X-Loadtest-Key": {"k"}, "Accept": {"*/*"}},
+            http.Header{"X-Loadtest-Key": {Redacted}, "Accept": {"*/*"}}},
+        {"authorization", http.Header{"Authorization": {"Bearer abc"}}, http.Header{"Authorization": {Redacted}}},
+        {"every cookie", http.Header{"Set-Cookie": {"a=1", "b=2"}, "Cookie": {"a=1"}},
+            http.Header{"Set-Cookie": {Redacted}, "Cookie": {Redacted}}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            orig := tt.in.Clone()
+            got := redactHeaders(tt.in)
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("got %v, want %v", got, tt.want)
+            }
+            if !reflect.DeepEqual(tt.in, orig) {
+                t.Errorf("input changed to %v", tt.in)
+            }
+        })
+    }
+}
+
+func TestRedactBody(t *testing.T) {
+    tests := []struct {
+        name      string
+        body      string
+        limit     int
+        want      string
+        truncated bool
+    }{
+        {"empty", "", 100, "", false},
+        {"no secrets", `{"deviceId":"d1"}`, 100, `{"deviceId":"d1"}`, false},
+        {"password", `{"email":"a@b","password":"hunter2"}`, 100, `{"email":"a@b","password":"[redacted]"}`, false},
+        {"key names match any case", `{"mqttPassword":"p","sessionToken":"t","clientSecret":"s"}`, 100,
+            `{"clientSecret":"[redacted]","mqttPassword":"[redacted]","sessionToken":"[redacted]"}`, false},
+        {"nested object", `{"user":{"name":"n","auth":{"token":"t"}}}`, 100,
+            `{"user":{"auth":{"token":"[redacted]"},"name":"n"}}`, false},
+        {"objects in arrays", `{"creds":[{"password":"a"},{"password":"b"}]}`, 100,
+            `{"creds":[{"password":"[redacted]"},{"password":"[redacted]"}]}`, false},
+        {"secret object replaced whole", `{"password":{"old":"a","new":"b"}}`, 100, `{"password":"[redacted]"}`, false},
+        {"not JSON only cut", "password=hunter2", 8, "password", true},
+        {"cap applies after redaction", `{"password":"` + strings.Repeat("x", 50) + `"}`, 24, `{"password":"[redacted]"}`[:24], true},
+        {"exactly at the cap", `{"a":1}`, 7, `{"a":1}`, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, truncated := redactBody([]byte(tt.body), tt.limit)
+            if got != tt.want || truncated != tt.truncated {
+                t.Errorf("got %q, %v; want %q, %v", got, truncated, tt.want, tt.truncated)
+            }
+        })
+    }
+}
+
+func TestCapturedEntries(t *testing.T) {
+    d := &MockDevice{}
+    for i := 0; i < CapturedEntries+5; i++ {
+        d.addHTTPLog(HTTPLogEntry{Status: 200, Capture: &HTTPCapture{RequestBody: "{}"}})
+    }
+    for i, e := range d.HTTPLog {
+        if kept := e.Capture != nil; kept != (i >= 5) {
+            t.Errorf("entry %d: capture kept = %v", i, kept)
+        }
+    }
+}
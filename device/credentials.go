@@ -0,0 +1,115 @@
+package device
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "strings"
+    "time"
+
+    pahomqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTT authentication modes accepted in Options.MQTTAuth.
+const (
+    MQTTAuthShared  = "shared"  // every device uses MQTTUsername/MQTTPassword (default)
+    MQTTAuthServer  = "server"  // fetch per-device credentials from the API after linking
+    MQTTAuthDerived = "derived" // username is the device ID, password DeriveMQTTPassword
+)
+
+// DeriveMQTTPassword returns the per-device broker password for the derived
+// auth mode: the hex HMAC-SHA256 of the device ID keyed with secret.
+func DeriveMQTTPassword(secret, deviceID string) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(deviceID))
+    return hex.EncodeToString(mac.Sum(nil))
+}
+
+// MQTTCredentialsPath returns the API path a linked device fetches its
+// broker credentials from.
+func MQTTCredentialsPath(deviceID string) string {
+    return "/device/" + url.PathEscape(deviceID) + "/mqtt-credentials"
+}
+
+func (h *httpClient) fetchMQTTCredentials() error {
+    status, body, err := h.doWithTimeout("GET", MQTTCredentialsPath(h.device.DeviceID), nil, h.timeouts.MQTTCreds)
+    if err != nil {
+        return err
+    }
+    if status != 200 {
+        return &StatusError{Op: "mqtt credentials", Status: status}
+    }
+    var creds struct {
+        Username string `json:"username"`
+        Password string `json:"password"`
+    }
+    if err := json.Unmarshal(body, &creds); err != nil {
+        return fmt.Errorf("mqtt credentials: %w", err)
+    }
+    if creds.Username == "" || creds.Password == "" {
+        return fmt.Errorf("mqtt credentials: empty username or password")
+    }
+    h.device.mqttClient.username = creds.Username
+    h.device.mqttClient.password = creds.Password
+    return nil
+}
+
+// SetIsolationPeer sets the device whose commands topic the CheckIsolation
+// step tries to subscribe to. Call before Run; without a peer the step is
+// skipped.
+func (d *MockDevice) SetIsolationPeer(deviceID string) {
+    d.isoPeer = deviceID
+}
+
+// ChecksIsolation reports whether the device runs the CheckIsolation step.
+func (d *MockDevice) ChecksIsolation() bool {
+    return d.opts.CheckIsolation && d.isoPeer != ""
+}
+
+// checkIsolation tries to subscribe to the isolation peer's commands topic.
+// The broker must refuse, in the SUBACK or by dropping the connection; a
+// granted subscription is recorded as a finding. Any other outcome leaves
+// isolation unverified and fails the step.
+func (m *mqttClient) checkIsolation() error {
+    if m.client == nil {
+        return fmt.Errorf("mqtt not connected")
+    }
+    topic := commandsTopic(m.device.isoPeer)
+    token := m.client.Subscribe(topic, 0, func(pahomqtt.Client, pahomqtt.Message) {})
+    if !token.WaitTimeout(m.timeouts.MQTTSubscribe) {
+        return fmt.Errorf("isolation check: subscribe timeout")
+    }
+    if err := token.Error(); err != nil {
+        if droppedByBroker(err) {
+            return nil
+        }
+        return fmt.Errorf("isolation check: %w", err)
+    }
+    qos, ok := token.(*pahomqtt.SubscribeToken).Result()[topic]
+    if !ok {
+        return fmt.Errorf("isolation check: no SUBACK for %s", topic)
+    }
+    if qos == 0x80 {
+        return nil
+    }
+    m.client.Unsubscribe(topic).WaitTimeout(m.timeouts.MQTTSubscribe)
+    m.device.addFinding(SecurityFinding{
+        Timestamp: time.Now(),
+        DeviceID:  m.device.DeviceID,
+        Kind:      FindingSubscribeGranted,
+        Topic:     topic,
+        Detail:    "isolation check",
+    })
+    return nil
+}
+
+// droppedByBroker reports whether a subscribe failed because the broker
+// closed the connection, which some brokers do instead of refusing in the
+// SUBACK. Paho then fails the pending token with "connection lost before
+// Subscribe completed".
+func droppedByBroker(err error) bool {
+    return strings.Contains(err.Error(), "connection lost")
+}
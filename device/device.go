@@ -1,7 +1,12 @@
 package device
 
 import (
+    "crypto/tls"
+    "encoding/json"
+    "errors"
     "fmt"
+    "math/rand"
+    "strings"
     "sync"
     "time"
 
@@ -19,6 +24,7 @@ const (
     StateLinking
     StateConfiguring
     StateActive
+    StateReconnecting // was active, lost the broker and is reconnecting
     StateError
     StateDone
 )
@@ -37,6 +43,8 @@ func (s State) String() string {
         return "CONFIGURING"
     case StateActive:
         return "ACTIVE"
+    case StateReconnecting:
+        return "RECONNECTING"
     case StateError:
         return "ERROR"
     case StateDone:
@@ -48,11 +56,15 @@ func (s State) String() string {
 
 // HTTPLogEntry records a single HTTP request/response.
 type HTTPLogEntry struct {
-    Timestamp time.Time
-    Method    string
-    Path      string
-    Status    int
-    OK        bool
+    Timestamp time.Time     `json:"timestamp"`
+    Method    string        `json:"method"`
+    Path      string        `json:"path"`
+    Endpoint  string        `json:"endpoint"` // Path with the device ID replaced by "{id}"
+    Status    int           `json:"status"`
+    OK        bool          `json:"ok"`
+    Duration  time.Duration `json:"-"` // encoded as duration_ms
+    Attempt   int           `json:"attempt,omitempty"` // lifecycle step attempt, 0 outside the lifecycle
+    Capture   *HTTPCapture  `json:"capture,omitempty"` // headers and bodies, nil unless Options.CaptureBodies
 }
 
 func (e HTTPLogEntry) String() string {
@@ -60,15 +72,67 @@ func (e HTTPLogEntry) String() string {
     if !e.OK {
         mark = "✗"
     }
-    return fmt.Sprintf("%s  %-6s %-40s  %d %s",
-        e.Timestamp.Format("15:04:05"), e.Method, e.Path, e.Status, mark)
+    return fmt.Sprintf("%s  %-6s %-40s  %d %s %s",
+        e.Timestamp.Format("15:04:05"), e.Method, e.Path, e.Status, mark, e.Duration.Round(time.Millisecond))
+}
+
+func (e HTTPLogEntry) MarshalJSON() ([]byte, error) {
+    type entry HTTPLogEntry
+    return json.Marshal(struct {
+        entry
+        DurationMS float64 `json:"duration_ms"`
+    }{entry(e), millis(e.Duration)})
+}
+
+// StepTiming records how long one lifecycle step took.
+type StepTiming struct {
+    Name     string        `json:"name"`
+    Attempt  int           `json:"attempt"`
+    Started  time.Time     `json:"started"`
+    Duration time.Duration `json:"-"` // encoded as duration_ms
+    Err      string        `json:"error,omitempty"`
+}
+
+func (t StepTiming) MarshalJSON() ([]byte, error) {
+    type timing StepTiming
+    return json.Marshal(struct {
+        timing
+        DurationMS float64 `json:"duration_ms"`
+    }{timing(t), millis(t.Duration)})
+}
+
+// millis converts d to fractional milliseconds for JSON output.
+func millis(d time.Duration) float64 {
+    return float64(d) / float64(time.Millisecond)
 }
 
 // MQTTMessage records an incoming MQTT message.
 type MQTTMessage struct {
-    Timestamp time.Time
-    Topic     string
-    Payload   string
+    Timestamp     time.Time
+    Topic         string
+    Payload       string
+    QoS           byte
+    Retained      bool
+    Latency       time.Duration // end-to-end push latency, zero if unknown
+    LatencySource string        // LatencyServerTS, LatencyTrigger or ""
+    Invalid       string        // why the payload failed validation; "" if valid or unchecked
+}
+
+// ConnEventType classifies an MQTT connection event.
+type ConnEventType string
+
+const (
+    ConnLost         ConnEventType = "lost"         // broker connection dropped
+    ConnReconnecting ConnEventType = "reconnecting" // an automatic reconnect attempt is starting
+    ConnReconnected  ConnEventType = "reconnected"  // a reconnect attempt succeeded
+)
+
+// ConnEvent records a change in a device's MQTT connection after the
+// initial connect.
+type ConnEvent struct {
+    Timestamp time.Time     `json:"timestamp"`
+    Type      ConnEventType `json:"type"`
+    Reason    string        `json:"reason,omitempty"`
 }
 
 func (m MQTTMessage) String() string {
@@ -86,16 +150,32 @@ type MockDevice struct {
     Password string
 
     // Assignment
-    Stop providers.Stop
+    Stop  providers.Stop   // the first configured stop; its provider labels the device
+    Stops []providers.Stop // every stop the device configures, Stop first
+    Seed  int64            // Options.Seed, which the device's own randomness derives from
 
     // State
-    State      State
-    ErrorMsg   string
-    HTTPLog    []HTTPLogEntry
-    MQTTMsgs   []MQTTMessage
-    MQTTCount  int
-    StartedAt  time.Time
-    ActiveAt   time.Time
+    State         State
+    ErrorMsg      string
+    HTTPLog       []HTTPLogEntry
+    StepLog       []StepTiming
+    MQTTMsgs      []MQTTMessage
+    MQTTCount     int
+    PayloadErrors int // commands messages that failed validation
+    ConnLog       []ConnEvent
+    Findings      []SecurityFinding // isolation check and probe results
+    StartedAt     time.Time
+    ActiveAt      time.Time
+
+    pushTrigger time.Time // last action expected to cause an MQTT push
+    attempt     int       // attempt number of the running lifecycle step
+    probePeers  []string  // device IDs to probe; empty for ordinary devices
+    isoPeer     string    // device ID for the CheckIsolation step
+
+    // Lifecycle
+    opts     Options
+    register bool       // fresh identity: register device and user before login
+    rng      *rand.Rand // retry jitter and other per-device randomness, see Options.Seed
 
     // Internal transport
     httpClient *httpClient
@@ -104,26 +184,120 @@ type MockDevice struct {
     doneCh     chan struct{}
 }
 
-// New creates a new MockDevice with the given provider assignment.
-func New(serverURL, secretKey, mqttHost, mqttUsername, mqttPassword string, mqttPort int, stop providers.Stop) *MockDevice {
+// Options holds the settings shared by every mock device in a run.
+type Options struct {
+    ServerURL    string
+    SecretKey    string
+    MQTTHost     string
+    MQTTPort     int
+    MQTTUsername string
+    MQTTPassword string
+
+    // MQTT transport: TransportTCP (default), TransportSSL, TransportWS or
+    // TransportWSS. MQTTPath is the websocket path; MQTTTLS is used for
+    // ssl and wss, with nil meaning the system roots.
+    MQTTTransport string
+    MQTTPath      string
+    MQTTTLS       *tls.Config
+
+    // MQTTAuth selects shared or per-device broker credentials (MQTTAuthShared,
+    // MQTTAuthServer or MQTTAuthDerived). With CheckIsolation, each device
+    // given a peer (see SetIsolationPeer) verifies after subscribing that it
+    // cannot subscribe to the peer's commands topic.
+    MQTTAuth       string
+    CheckIsolation bool
+
+    // ValidatePayloads checks every commands message against the arrival
+    // board schema and the device's configured stops. The schema is the
+    // mock server's, so it is off unless asked for.
+    ValidatePayloads bool
+
+    // CaptureBodies keeps the headers and the first CaptureLimit bytes
+    // (default DefaultCaptureLimit) of every request and response body in
+    // the newest CapturedEntries HTTP log entries, with secrets redacted.
+    CaptureBodies bool
+    CaptureLimit  int
+
+    // Lifecycle shortcuts for devices whose identity already exists on the
+    // server (see NewWithIdentity). SkipConfig only skips the config POST;
+    // the device still fetches its config like a rebooting display does.
+    SkipLink   bool
+    SkipConfig bool
+
+    // Timeouts per lifecycle step; zero fields use DefaultTimeouts.
+    Timeouts Timeouts
+
+    // Retry applies to every lifecycle step; StepRetry overrides it per
+    // step key (see StepKeys), inheriting any zero fields from Retry.
+    Retry     RetryPolicy
+    StepRetry map[string]RetryPolicy
+
+    // Seed seeds the device's own randomness such as retry jitter, so a
+    // seeded run can be replayed. The runner gives each device its own.
+    Seed int64
+}
+
+// Identity is the set of credentials a device and its user are created with.
+type Identity struct {
+    DeviceID string
+    Email    string
+    Password string
+}
+
+// NewIdentity generates a fresh loadtest identity. IDs and passwords always
+// come from crypto/rand, never from the run seed, so a seed neither reveals
+// passwords nor collides with the identities of an earlier run.
+func NewIdentity() Identity {
     id := uuid.New().String()
-    deviceID := "loadtest-" + id
-    email := "loadtest-" + id + "@test.invalid"
-    password := uuid.New().String()
+    return Identity{
+        DeviceID: "loadtest-" + id,
+        Email:    "loadtest-" + id + "@test.invalid",
+        Password: uuid.New().String(),
+    }
+}
+
+// New creates a new MockDevice for a fresh identity (see NewIdentity) that
+// configures the given stops, of which there must be at least one. It
+// registers the identity before login.
+func New(opts Options, id Identity, stops []providers.Stop) *MockDevice {
+    d := newDevice(opts, id, stops)
+    d.register = true
+    return d
+}
 
+// NewWithIdentity creates a MockDevice for an identity that is already
+// registered on the server. Its lifecycle starts at login.
+func NewWithIdentity(opts Options, id Identity, stops []providers.Stop) *MockDevice {
+    return newDevice(opts, id, stops)
+}
+
+func newDevice(opts Options, id Identity, stops []providers.Stop) *MockDevice {
+    short := strings.TrimPrefix(id.DeviceID, "loadtest-")
+    if len(short) > 8 {
+        short = short[:8]
+    }
     d := &MockDevice{
-        ShortID:  id[:8],
-        DeviceID: deviceID,
-        Email:    email,
-        Password: password,
-        Stop:     stop,
-        State:    StateInit,
+        ShortID:   short,
+        DeviceID:  id.DeviceID,
+        Email:     id.Email,
+        Password:  id.Password,
+        Stop:      stops[0],
+        Stops:     stops,
+        Seed:      opts.Seed,
+        State:     StateInit,
         StartedAt: time.Now(),
-        stopCh:   make(chan struct{}),
-        doneCh:   make(chan struct{}),
+        opts:      opts,
+        rng:       rand.New(rand.NewSource(opts.Seed)),
+        stopCh:    make(chan struct{}),
+        doneCh:    make(chan struct{}),
+    }
+    timeouts := opts.Timeouts.withDefaults()
+    d.httpClient = newHTTPClient(opts.ServerURL, opts.SecretKey, timeouts, opts.captureLimit(), d)
+    d.mqttClient = newMQTTClient(opts, timeouts, d)
+    if opts.MQTTAuth == MQTTAuthDerived {
+        d.mqttClient.username = d.DeviceID
+        d.mqttClient.password = DeriveMQTTPassword(opts.MQTTPassword, d.DeviceID)
     }
-    d.httpClient = newHTTPClient(serverURL, secretKey, d)
-    d.mqttClient = newMQTTClient(mqttHost, mqttPort, mqttUsername, mqttPassword, d)
     return d
 }
 
@@ -131,28 +305,21 @@ func New(serverURL, secretKey, mqttHost, mqttUsername, mqttPassword string, mqtt
 func (d *MockDevice) Run(eventCh chan<- Event) {
     defer close(d.doneCh)
 
-    steps := []struct {
-        name string
-        fn   func() error
-    }{
-        {"register device", d.httpClient.registerDevice},
-        {"register user", d.httpClient.registerUser},
-        {"login", d.httpClient.login},
-        {"link device", d.httpClient.linkDevice},
-        {"set config", d.httpClient.setConfig},
-        {"get config", d.httpClient.getConfig},
-        {"connect mqtt", d.mqttClient.connect},
-        {"subscribe mqtt", d.mqttClient.subscribe},
-    }
-
-    for _, step := range steps {
+    for _, step := range d.steps() {
         select {
         case <-d.stopCh:
+            d.mqttClient.disconnect()
             d.setState(StateDone)
             return
         default:
         }
-        if err := step.fn(); err != nil {
+        if err := d.runStep(step); err != nil {
+            // A later step may fail with the broker session already open.
+            d.mqttClient.disconnect()
+            if errors.Is(err, errStopped) {
+                d.setState(StateDone)
+                return
+            }
             d.setError(fmt.Sprintf("%s: %v", step.name, err))
             eventCh <- Event{DeviceID: d.DeviceID, Type: EventError}
             return
@@ -169,6 +336,77 @@ func (d *MockDevice) Run(eventCh chan<- Event) {
     d.cleanup(eventCh)
 }
 
+// errStopped aborts a step whose retry backoff was cut short by Shutdown.
+var errStopped = errors.New("stopped")
+
+// runStep runs a lifecycle step, retrying it according to its policy. Every
+// attempt is timed separately.
+func (d *MockDevice) runStep(step lifecycleStep) error {
+    policy := d.opts.retryPolicy(step.key)
+    defer d.setAttempt(0)
+    for attempt := 1; ; attempt++ {
+        d.setAttempt(attempt)
+        started := time.Now()
+        err := step.fn()
+        timing := StepTiming{Name: step.name, Attempt: attempt, Started: started, Duration: time.Since(started)}
+        if err != nil {
+            timing.Err = err.Error()
+        }
+        d.addStepTiming(timing)
+        if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(err) {
+            return err
+        }
+        select {
+        case <-d.stopCh:
+            return errStopped
+        case <-time.After(policy.delay(attempt, d.rng)):
+        }
+    }
+}
+
+type lifecycleStep struct {
+    name string
+    key  string // see StepKeys
+    fn   func() error
+}
+
+// steps returns the lifecycle for this device: the full cold-start path for
+// fresh identities, or the reboot path starting at login for reused ones.
+func (d *MockDevice) steps() []lifecycleStep {
+    var steps []lifecycleStep
+    if d.register {
+        steps = append(steps,
+            lifecycleStep{"register device", "register", d.httpClient.registerDevice},
+            lifecycleStep{"register user", "register", d.httpClient.registerUser},
+        )
+    }
+    steps = append(steps, lifecycleStep{"login", "login", d.httpClient.login})
+    if d.register || !d.opts.SkipLink {
+        steps = append(steps, lifecycleStep{"link device", "link", d.httpClient.linkDevice})
+    }
+    if d.register || !d.opts.SkipConfig {
+        steps = append(steps, lifecycleStep{"set config", "set-config", d.httpClient.setConfig})
+    }
+    steps = append(steps,
+        lifecycleStep{"get config", "get-config", d.httpClient.getConfig},
+    )
+    if d.opts.MQTTAuth == MQTTAuthServer {
+        steps = append(steps, lifecycleStep{"mqtt credentials", "mqtt-credentials", d.httpClient.fetchMQTTCredentials})
+    }
+    steps = append(steps,
+        lifecycleStep{"connect mqtt", "mqtt-connect", d.mqttClient.connect},
+        lifecycleStep{"subscribe mqtt", "mqtt-subscribe", d.mqttClient.subscribe},
+    )
+    if d.ChecksIsolation() {
+        // No key: a granted subscription is a finding, not worth a retry.
+        steps = append(steps, lifecycleStep{"check isolation", "", d.mqttClient.checkIsolation})
+    }
+    if d.IsProbe() {
+        steps = append(steps, lifecycleStep{"probe isolation", "", d.mqttClient.probeIsolation})
+    }
+    return steps
+}
+
 // Shutdown signals the device to shut down gracefully.
 func (d *MockDevice) Shutdown() {
     select {
@@ -185,6 +423,7 @@ func (d *MockDevice) Wait() {
 
 // ForceRefresh triggers a manual refresh of transit data for this device.
 func (d *MockDevice) ForceRefresh() error {
+    d.markTrigger(time.Now())
     return d.httpClient.refresh()
 }
 
@@ -204,6 +443,30 @@ func (d *MockDevice) GetHTTPLog() []HTTPLogEntry {
     return cp
 }
 
+// HTTPLogSince returns a copy of the HTTP log entries from index i onward (thread-safe).
+func (d *MockDevice) HTTPLogSince(i int) []HTTPLogEntry {
+    d.mu.RLock()
+    defer d.mu.RUnlock()
+    if i >= len(d.HTTPLog) {
+        return nil
+    }
+    cp := make([]HTTPLogEntry, len(d.HTTPLog)-i)
+    copy(cp, d.HTTPLog[i:])
+    return cp
+}
+
+// StepLogSince returns a copy of the lifecycle step timings from index i onward (thread-safe).
+func (d *MockDevice) StepLogSince(i int) []StepTiming {
+    d.mu.RLock()
+    defer d.mu.RUnlock()
+    if i >= len(d.StepLog) {
+        return nil
+    }
+    cp := make([]StepTiming, len(d.StepLog)-i)
+    copy(cp, d.StepLog[i:])
+    return cp
+}
+
 // GetMQTTMsgs returns a copy of recent MQTT messages (thread-safe).
 func (d *MockDevice) GetMQTTMsgs() []MQTTMessage {
     d.mu.RLock()
@@ -213,6 +476,39 @@ func (d *MockDevice) GetMQTTMsgs() []MQTTMessage {
     return cp
 }
 
+// MQTTMsgsSince returns a copy of the MQTT messages from index i onward (thread-safe).
+func (d *MockDevice) MQTTMsgsSince(i int) []MQTTMessage {
+    d.mu.RLock()
+    defer d.mu.RUnlock()
+    if i >= len(d.MQTTMsgs) {
+        return nil
+    }
+    cp := make([]MQTTMessage, len(d.MQTTMsgs)-i)
+    copy(cp, d.MQTTMsgs[i:])
+    return cp
+}
+
+// GetConnLog returns a copy of the MQTT connection events (thread-safe).
+func (d *MockDevice) GetConnLog() []ConnEvent {
+    d.mu.RLock()
+    defer d.mu.RUnlock()
+    cp := make([]ConnEvent, len(d.ConnLog))
+    copy(cp, d.ConnLog)
+    return cp
+}
+
+// ConnLogSince returns a copy of the MQTT connection events from index i onward (thread-safe).
+func (d *MockDevice) ConnLogSince(i int) []ConnEvent {
+    d.mu.RLock()
+    defer d.mu.RUnlock()
+    if i >= len(d.ConnLog) {
+        return nil
+    }
+    cp := make([]ConnEvent, len(d.ConnLog)-i)
+    copy(cp, d.ConnLog[i:])
+    return cp
+}
+
 // GetMQTTCount returns total MQTT messages received (thread-safe).
 func (d *MockDevice) GetMQTTCount() int {
     d.mu.RLock()
@@ -220,6 +516,36 @@ func (d *MockDevice) GetMQTTCount() int {
     return d.MQTTCount
 }
 
+// GetPayloadErrors returns how many commands messages failed validation (thread-safe).
+func (d *MockDevice) GetPayloadErrors() int {
+    d.mu.RLock()
+    defer d.mu.RUnlock()
+    return d.PayloadErrors
+}
+
+// Failed reports whether the device ended in StateError or received an
+// invalid payload (thread-safe).
+func (d *MockDevice) Failed() bool {
+    d.mu.RLock()
+    defer d.mu.RUnlock()
+    return d.State == StateError || d.PayloadErrors > 0
+}
+
+// ReachedActive reports whether the device ever completed its lifecycle (thread-safe).
+func (d *MockDevice) ReachedActive() bool {
+    d.mu.RLock()
+    defer d.mu.RUnlock()
+    return !d.ActiveAt.IsZero()
+}
+
+// GetActiveAt returns when the device completed its lifecycle, or the zero
+// time if it never did (thread-safe).
+func (d *MockDevice) GetActiveAt() time.Time {
+    d.mu.RLock()
+    defer d.mu.RUnlock()
+    return d.ActiveAt
+}
+
 // GetErrorMsg returns the last error message (thread-safe).
 func (d *MockDevice) GetErrorMsg() string {
     d.mu.RLock()
@@ -227,21 +553,65 @@ func (d *MockDevice) GetErrorMsg() string {
     return d.ErrorMsg
 }
 
-// addHTTPLog appends an HTTP log entry (must be called with mu held or with lock).
+// addHTTPLog appends an HTTP log entry, tagging it with the current step
+// attempt, and drops the capture of the entry that falls out of the newest
+// CapturedEntries (thread-safe).
 func (d *MockDevice) addHTTPLog(entry HTTPLogEntry) {
     d.mu.Lock()
+    entry.Attempt = d.attempt
     d.HTTPLog = append(d.HTTPLog, entry)
+    if i := len(d.HTTPLog) - 1 - CapturedEntries; i >= 0 {
+        d.HTTPLog[i].Capture = nil
+    }
     d.mu.Unlock()
 }
 
-// addMQTTMsg appends an MQTT message (thread-safe).
+// addStepTiming appends a lifecycle step timing (thread-safe).
+func (d *MockDevice) addStepTiming(t StepTiming) {
+    d.mu.Lock()
+    d.StepLog = append(d.StepLog, t)
+    d.mu.Unlock()
+}
+
+// addMQTTMsg appends an MQTT message, working out its push latency (thread-safe).
 func (d *MockDevice) addMQTTMsg(msg MQTTMessage) {
     d.mu.Lock()
+    // A retained message was published before we subscribed, so its age
+    // says nothing about push latency.
+    if !msg.Retained {
+        msg.Latency, msg.LatencySource = d.pushLatency([]byte(msg.Payload), msg.Timestamp)
+    }
+    if d.opts.ValidatePayloads && msg.Topic == commandsTopic(d.DeviceID) {
+        msg.Invalid = validateCommand([]byte(msg.Payload), d.Stops)
+        if msg.Invalid != "" {
+            d.PayloadErrors++
+        }
+    }
     d.MQTTMsgs = append(d.MQTTMsgs, msg)
     d.MQTTCount++
     d.mu.Unlock()
 }
 
+// addConnEvent records an MQTT connection event and moves an active device
+// into or out of StateReconnecting (thread-safe).
+func (d *MockDevice) addConnEvent(ev ConnEvent) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.ConnLog = append(d.ConnLog, ev)
+    switch {
+    case ev.Type == ConnLost && d.State == StateActive:
+        d.State = StateReconnecting
+    case ev.Type == ConnReconnected && d.State == StateReconnecting:
+        d.State = StateActive
+    }
+}
+
+func (d *MockDevice) setAttempt(n int) {
+    d.mu.Lock()
+    d.attempt = n
+    d.mu.Unlock()
+}
+
 func (d *MockDevice) setState(s State) {
     d.mu.Lock()
     d.State = s
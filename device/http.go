@@ -4,7 +4,6 @@ import (
     "bytes"
     "context"
     "encoding/json"
-    "fmt"
     "io"
     "net/http"
     "net/http/cookiejar"
@@ -16,15 +15,19 @@ import (
 type httpClient struct {
     base      string
     secretKey string
+    timeouts  Timeouts
+    capture   int // bytes of each body kept in the HTTP log; 0 captures nothing
     device    *MockDevice
     client    *http.Client
 }
 
-func newHTTPClient(serverURL, secretKey string, d *MockDevice) *httpClient {
+func newHTTPClient(serverURL, secretKey string, timeouts Timeouts, capture int, d *MockDevice) *httpClient {
     jar, _ := cookiejar.New(nil)
     return &httpClient{
         base:      strings.TrimRight(serverURL, "/"),
         secretKey: secretKey,
+        timeouts:  timeouts,
+        capture:   capture,
         device:    d,
         client: &http.Client{
             // No global timeout — each call passes its own via doWithTimeout.
@@ -35,18 +38,20 @@ func newHTTPClient(serverURL, secretKey string, d *MockDevice) *httpClient {
 
 // do performs an HTTP request with the default 15-second timeout.
 func (h *httpClient) do(method, path string, body interface{}) (int, []byte, error) {
-    return h.doWithTimeout(method, path, body, 15*time.Second)
+    return h.doWithTimeout(method, path, body, defaultHTTPTimeout)
 }
 
 // doWithTimeout performs an HTTP request with a caller-specified timeout.
 func (h *httpClient) doWithTimeout(method, path string, body interface{}, timeout time.Duration) (int, []byte, error) {
     var bodyReader io.Reader
+    var reqBody []byte
     if body != nil {
         b, err := json.Marshal(body)
         if err != nil {
             return 0, nil, err
         }
         bodyReader = bytes.NewReader(b)
+        reqBody = b
     }
 
     req, err := http.NewRequest(method, h.base+path, bodyReader)
@@ -64,44 +69,78 @@ func (h *httpClient) doWithTimeout(method, path string, body interface{}, timeou
     defer cancel()
     req = req.WithContext(ctx)
 
+    start := time.Now()
     resp, err := h.client.Do(req)
     if err != nil {
         h.device.addHTTPLog(HTTPLogEntry{
             Timestamp: time.Now(),
             Method:    method,
             Path:      path,
+            Endpoint:  h.endpoint(path),
             Status:    0,
             OK:        false,
+            Duration:  time.Since(start),
+            Capture:   h.captureExchange(req, reqBody, nil, nil),
         })
         return 0, nil, err
     }
     defer resp.Body.Close()
 
+    // Latency includes reading the body, as the firmware must before acting on it.
     respBody, _ := io.ReadAll(resp.Body)
+    elapsed := time.Since(start)
     ok := resp.StatusCode >= 200 && resp.StatusCode < 300
     h.device.addHTTPLog(HTTPLogEntry{
         Timestamp: time.Now(),
         Method:    method,
         Path:      path,
+        Endpoint:  h.endpoint(path),
         Status:    resp.StatusCode,
         OK:        ok,
+        Duration:  elapsed,
+        Capture:   h.captureExchange(req, reqBody, resp, respBody),
     })
 
     return resp.StatusCode, respBody, nil
 }
 
+// captureExchange records req and resp for the HTTP log with secrets
+// redacted, or returns nil if capture is off. resp is nil if the request
+// failed before a response arrived.
+func (h *httpClient) captureExchange(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte) *HTTPCapture {
+    if h.capture <= 0 {
+        return nil
+    }
+    c := &HTTPCapture{RequestHeaders: redactHeaders(req.Header)}
+    var cut bool
+    c.RequestBody, cut = redactBody(reqBody, h.capture)
+    c.Truncated = cut
+    if resp != nil {
+        c.ResponseHeaders = redactHeaders(resp.Header)
+        c.ResponseBody, cut = redactBody(respBody, h.capture)
+        c.Truncated = c.Truncated || cut
+    }
+    return c
+}
+
+// endpoint normalises a request path so that all devices share one key per
+// route, e.g. "/device/loadtest-…/config" becomes "/device/{id}/config".
+func (h *httpClient) endpoint(path string) string {
+    return strings.Replace(path, url.PathEscape(h.device.DeviceID), "{id}", 1)
+}
+
 func (h *httpClient) registerDevice() error {
     h.device.setState(StateRegistering)
     payload := map[string]string{
         "id":       h.device.DeviceID,
         "timezone": "America/New_York",
     }
-    status, _, err := h.do("POST", "/device/register", payload)
+    status, _, err := h.doWithTimeout("POST", "/device/register", payload, h.timeouts.Register)
     if err != nil {
         return err
     }
     if status != 200 && status != 201 {
-        return fmt.Errorf("register device returned %d", status)
+        return &StatusError{Op: "register device", Status: status}
     }
     return nil
 }
@@ -111,12 +150,12 @@ func (h *httpClient) registerUser() error {
         "email":    h.device.Email,
         "password": h.device.Password,
     }
-    status, _, err := h.do("POST", "/user/register", payload)
+    status, _, err := h.doWithTimeout("POST", "/user/register", payload, h.timeouts.Register)
     if err != nil {
         return err
     }
     if status != 200 && status != 201 {
-        return fmt.Errorf("register user returned %d", status)
+        return &StatusError{Op: "register user", Status: status}
     }
     return nil
 }
@@ -127,12 +166,12 @@ func (h *httpClient) login() error {
         "email":    h.device.Email,
         "password": h.device.Password,
     }
-    status, _, err := h.do("POST", "/auth/login", payload)
+    status, _, err := h.doWithTimeout("POST", "/auth/login", payload, h.timeouts.Login)
     if err != nil {
         return err
     }
     if status != 200 && status != 201 {
-        return fmt.Errorf("login returned %d", status)
+        return &StatusError{Op: "login", Status: status}
     }
     return nil
 }
@@ -142,50 +181,51 @@ func (h *httpClient) linkDevice() error {
     payload := map[string]string{
         "deviceId": h.device.DeviceID,
     }
-    status, _, err := h.do("POST", "/user/device/link", payload)
+    status, _, err := h.doWithTimeout("POST", "/user/device/link", payload, h.timeouts.Link)
     if err != nil {
         return err
     }
     if status != 200 && status != 201 {
-        return fmt.Errorf("link device returned %d", status)
+        return &StatusError{Op: "link device", Status: status}
     }
     return nil
 }
 
 func (h *httpClient) setConfig() error {
     h.device.setState(StateConfiguring)
-    stop := h.device.Stop
     // Server expects { lines: [{ provider, line, stop, direction }] }
+    lines := make([]map[string]string, 0, len(h.device.Stops))
+    for _, stop := range h.device.Stops {
+        lines = append(lines, map[string]string{
+            "provider":  stop.ProviderID,
+            "line":      stop.Line,
+            "stop":      stop.StopID,
+            "direction": stop.Direction,
+        })
+    }
     payload := map[string]interface{}{
-        "lines": []map[string]string{
-            {
-                "provider":  stop.ProviderID,
-                "line":      stop.Line,
-                "stop":      stop.StopID,
-                "direction": stop.Direction,
-            },
-        },
+        "lines": lines,
     }
     path := "/device/" + url.PathEscape(h.device.DeviceID) + "/config"
-    // Config POST triggers a live provider fetch on the server — give it 90s.
-    status, _, err := h.doWithTimeout("POST", path, payload, 90*time.Second)
+    h.device.markTrigger(time.Now())
+    status, _, err := h.doWithTimeout("POST", path, payload, h.timeouts.SetConfig)
     if err != nil {
         return err
     }
     if status != 200 && status != 201 {
-        return fmt.Errorf("set config returned %d", status)
+        return &StatusError{Op: "set config", Status: status}
     }
     return nil
 }
 
 func (h *httpClient) getConfig() error {
     path := "/device/" + url.PathEscape(h.device.DeviceID) + "/config"
-    status, _, err := h.do("GET", path, nil)
+    status, _, err := h.doWithTimeout("GET", path, nil, h.timeouts.GetConfig)
     if err != nil {
         return err
     }
     if status != 200 {
-        return fmt.Errorf("get config returned %d", status)
+        return &StatusError{Op: "get config", Status: status}
     }
     return nil
 }
@@ -197,7 +237,7 @@ func (h *httpClient) refresh() error {
         return err
     }
     if status != 200 && status != 201 {
-        return fmt.Errorf("refresh returned %d", status)
+        return &StatusError{Op: "refresh", Status: status}
     }
     return nil
 }
@@ -208,7 +248,7 @@ func (h *httpClient) logout() error {
         return err
     }
     if status != 200 && status != 204 {
-        return fmt.Errorf("logout returned %d", status)
+        return &StatusError{Op: "logout", Status: status}
     }
     return nil
 }
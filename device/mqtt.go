@@ -1,6 +1,7 @@
 package device
 
 import (
+    "crypto/tls"
     "fmt"
     "time"
 
@@ -8,30 +9,49 @@ import (
 )
 
 type mqttClient struct {
-    host     string
-    port     int
-    username string
-    password string
-    device   *MockDevice
-    client   pahomqtt.Client
+    host      string
+    port      int
+    username  string
+    password  string
+    transport string
+    path      string
+    tls       *tls.Config
+    timeouts  Timeouts
+    device    *MockDevice
+    client    pahomqtt.Client
+
+    connected bool // set after the first successful connect; later ones are reconnects
 }
 
-func newMQTTClient(host string, port int, username, password string, d *MockDevice) *mqttClient {
+func newMQTTClient(opts Options, timeouts Timeouts, d *MockDevice) *mqttClient {
     return &mqttClient{
-        host:     host,
-        port:     port,
-        username: username,
-        password: password,
-        device:   d,
+        host:      opts.MQTTHost,
+        port:      opts.MQTTPort,
+        username:  opts.MQTTUsername,
+        password:  opts.MQTTPassword,
+        transport: opts.MQTTTransport,
+        path:      opts.MQTTPath,
+        tls:       opts.MQTTTLS,
+        timeouts:  timeouts,
+        device:    d,
     }
 }
 
-func (m *mqttClient) presenceTopic() string {
-    return "device/" + m.device.DeviceID + "/presence"
+// commandsTopic is where a device receives arrival pushes.
+func commandsTopic(deviceID string) string {
+    return "/device/" + deviceID + "/commands"
+}
+
+// presenceTopic carries a device's retained online/offline status.
+func presenceTopic(deviceID string) string {
+    return "device/" + deviceID + "/presence"
 }
 
 func (m *mqttClient) connect() error {
-    broker := fmt.Sprintf("tcp://%s:%d", m.host, m.port)
+    broker, err := BrokerURL(m.transport, m.host, m.port, m.path)
+    if err != nil {
+        return err
+    }
     opts := pahomqtt.NewClientOptions().
         AddBroker(broker).
         SetClientID(m.device.DeviceID).
@@ -39,46 +59,93 @@ func (m *mqttClient) connect() error {
         SetPassword(m.password).
         SetCleanSession(true).
         SetAutoReconnect(true).
-        SetConnectTimeout(10 * time.Second).
-        SetWill(m.presenceTopic(), "offline", 0, true).
-        SetOnConnectHandler(func(c pahomqtt.Client) {
-            c.Publish(m.presenceTopic(), 0, true, "online")
+        SetConnectTimeout(m.timeouts.MQTTConnect).
+        SetWill(presenceTopic(m.device.DeviceID), "offline", 0, true).
+        SetOnConnectHandler(m.onConnect).
+        SetConnectionLostHandler(func(_ pahomqtt.Client, err error) {
+            m.device.addConnEvent(ConnEvent{Timestamp: time.Now(), Type: ConnLost, Reason: err.Error()})
         }).
-        SetConnectionLostHandler(func(c pahomqtt.Client, err error) {})
+        SetReconnectingHandler(func(pahomqtt.Client, *pahomqtt.ClientOptions) {
+            m.device.addConnEvent(ConnEvent{Timestamp: time.Now(), Type: ConnReconnecting})
+        })
 
+    if m.tls != nil {
+        opts.SetTLSConfig(m.tls)
+    }
+
+    // A client whose connect timed out may still be dialling, so it is
+    // disconnected before being dropped; the next attempt starts afresh.
     client := pahomqtt.NewClient(opts)
     token := client.Connect()
-    if !token.WaitTimeout(10 * time.Second) {
+    if !token.WaitTimeout(m.timeouts.MQTTConnect) {
+        client.Disconnect(0)
         return fmt.Errorf("mqtt connect timeout")
     }
     if err := token.Error(); err != nil {
+        client.Disconnect(0)
         return fmt.Errorf("mqtt connect: %w", err)
     }
     m.client = client
     return nil
 }
 
+// onConnect runs after every successful connect. The session is clean, so
+// after a reconnect the device must resubscribe, as the firmware does.
+// Paho calls it on its own goroutine, so waiting on tokens here is safe.
+func (m *mqttClient) onConnect(c pahomqtt.Client) {
+    c.Publish(presenceTopic(m.device.DeviceID), 0, true, "online")
+
+    m.device.mu.Lock()
+    reconnect := m.connected
+    m.connected = true
+    m.device.mu.Unlock()
+    if !reconnect {
+        return
+    }
+    ev := ConnEvent{Timestamp: time.Now(), Type: ConnReconnected}
+    if err := m.subscribeWith(c); err != nil {
+        ev.Reason = "resubscribe: " + err.Error()
+    }
+    m.device.addConnEvent(ev)
+}
+
 func (m *mqttClient) subscribe() error {
     if m.client == nil {
         return fmt.Errorf("mqtt not connected")
     }
-    topic := "/device/" + m.device.DeviceID + "/commands"
-    token := m.client.Subscribe(topic, 0, func(_ pahomqtt.Client, msg pahomqtt.Message) {
+    return m.subscribeWith(m.client)
+}
+
+func (m *mqttClient) subscribeWith(c pahomqtt.Client) error {
+    topic := commandsTopic(m.device.DeviceID)
+    token := c.Subscribe(topic, 0, func(_ pahomqtt.Client, msg pahomqtt.Message) {
         m.device.addMQTTMsg(MQTTMessage{
             Timestamp: time.Now(),
             Topic:     msg.Topic(),
             Payload:   string(msg.Payload()),
+            QoS:       msg.Qos(),
+            Retained:  msg.Retained(),
         })
     })
-    if !token.WaitTimeout(5 * time.Second) {
+    if !token.WaitTimeout(m.timeouts.MQTTSubscribe) {
         return fmt.Errorf("mqtt subscribe timeout")
     }
-    return token.Error()
+    if err := token.Error(); err != nil {
+        return err
+    }
+    m.device.markSubscribed(time.Now())
+    return nil
 }
 
+// disconnect ends the session, announcing the device offline if it is still
+// connected. A client that is reconnecting is stopped too.
 func (m *mqttClient) disconnect() {
-    if m.client != nil && m.client.IsConnected() {
-        m.client.Publish(m.presenceTopic(), 0, true, "offline").Wait()
-        m.client.Disconnect(500)
+    if m.client == nil {
+        return
+    }
+    if m.client.IsConnected() {
+        m.client.Publish(presenceTopic(m.device.DeviceID), 0, true, "offline").Wait()
     }
+    m.client.Disconnect(500)
+    m.client = nil
 }
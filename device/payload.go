@@ -0,0 +1,87 @@
+package device
+
+import (
+    "encoding/json"
+    "fmt"
+    "slices"
+    "strings"
+    "time"
+
+    "github.com/commute-live/loadtest/providers"
+)
+
+// CommandPayload is the arrival board the server pushes on a device's
+// commands topic. Its publish time may be under any of
+// serverTimestampKeys, so it is read with serverTimestamp instead.
+type CommandPayload struct {
+    Lines []BoardLine `json:"lines"`
+}
+
+// BoardLine holds the upcoming arrivals for one configured line.
+type BoardLine struct {
+    Provider  string      `json:"provider"` // the provider ID the device configured, e.g. "mta-subway"
+    Line      string      `json:"line"`
+    Stop      string      `json:"stop"`
+    Direction string      `json:"direction"`
+    Arrivals  []time.Time `json:"arrivals"`
+}
+
+// validateCommand parses a commands-topic payload and checks it against the
+// arrival board schema and the stops the device configured: every line must
+// be one of them, and every one of them must have a line. A line may have
+// no upcoming arrivals, but must say so with an empty list. It returns a
+// description of every problem found, or "" if the payload is valid.
+func validateCommand(payload []byte, stops []providers.Stop) string {
+    var p CommandPayload
+    if err := json.Unmarshal(payload, &p); err != nil {
+        return "malformed JSON: " + err.Error()
+    }
+    var problems []string
+    add := func(format string, args ...any) {
+        problems = append(problems, fmt.Sprintf(format, args...))
+    }
+    if _, ok := serverTimestamp(payload); !ok {
+        add("missing publish time (%s)", strings.Join(serverTimestampKeys, ", "))
+    }
+    if p.Lines == nil {
+        add("missing lines")
+    }
+    for i, l := range p.Lines {
+        if l.Provider == "" || l.Line == "" || l.Stop == "" {
+            add("lines[%d]: provider, line and stop are required", i)
+            continue
+        }
+        if l.Arrivals == nil {
+            add("lines[%d]: missing arrivals", i)
+        }
+        for j, t := range l.Arrivals {
+            if t.IsZero() {
+                add("lines[%d].arrivals[%d]: zero time", i, j)
+            }
+        }
+        if !slices.ContainsFunc(stops, func(s providers.Stop) bool { return boards(l, s) }) {
+            add("lines[%d]: %s not configured on this device", i, boardKey(l.Provider, l.Line, l.Stop, l.Direction))
+        }
+    }
+    if p.Lines != nil {
+        for _, s := range stops {
+            if !slices.ContainsFunc(p.Lines, func(l BoardLine) bool { return boards(l, s) }) {
+                add("no line for configured %s", boardKey(s.ProviderID, s.Line, s.StopID, s.Direction))
+            }
+        }
+    }
+    return strings.Join(problems, "; ")
+}
+
+// boards reports whether l is the arrival board for stop s.
+func boards(l BoardLine, s providers.Stop) bool {
+    return l.Provider == s.ProviderID && l.Line == s.Line && l.Stop == s.StopID && l.Direction == s.Direction
+}
+
+func boardKey(provider, line, stop, direction string) string {
+    k := provider + "/" + line + "/" + stop
+    if direction != "" {
+        k += "/" + direction
+    }
+    return k
+}
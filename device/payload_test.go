@@ -0,0 +1,66 @@
+package device
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/commute-live/loadtest/providers"
+)
+
+func TestValidateCommand(t *testing.T) {
+    red := providers.Stop{Provider: "cta", ProviderID: "cta", Line: "Red", StopID: "40380", Direction: "N"}
+    blue := providers.Stop{Provider: "cta", ProviderID: "cta", Line: "Blue", StopID: "40490"}
+    redLine := `{"provider":"cta","line":"Red","stop":"40380","direction":"N","arrivals":["2026-01-01T12:05:00Z"]}`
+    blueLine := `{"provider":"cta","line":"Blue","stop":"40490","arrivals":["2026-01-01T12:07:00Z"]}`
+    board := func(lines ...string) string {
+        return `{"sentAt":"2026-01-01T12:00:00Z","lines":[` + strings.Join(lines, ",") + `]}`
+    }
+
+    tests := []struct {
+        name    string
+        payload string
+        stops   []providers.Stop
+        want    []string // substrings of the result; none means valid
+    }{
+        {"valid", board(redLine), []providers.Stop{red}, nil},
+        {"valid multi-line", board(redLine, blueLine), []providers.Stop{red, blue}, nil},
+        {"unix millis timestamp", `{"ts":1767268800000,"lines":[` + redLine + `]}`, []providers.Stop{red}, nil},
+        {"malformed JSON", `{"lines":[`, []providers.Stop{red}, []string{"malformed JSON"}},
+        {"missing publish time", `{"lines":[` + redLine + `]}`, []providers.Stop{red}, []string{"missing publish time"}},
+        {"missing lines", `{"sentAt":"2026-01-01T12:00:00Z"}`, []providers.Stop{red}, []string{"missing lines"}},
+        {"wrong provider", board(strings.Replace(redLine, `"provider":"cta"`, `"provider":"mta-subway"`, 1)),
+            []providers.Stop{red}, []string{"lines[0]: mta-subway/Red/40380/N not configured", "no line for configured cta/Red/40380/N"}},
+        {"wrong line", board(strings.Replace(redLine, `"Red"`, `"Brown"`, 1)),
+            []providers.Stop{red}, []string{"lines[0]: cta/Brown/40380/N not configured"}},
+        {"wrong stop", board(strings.Replace(redLine, `"40380"`, `"40381"`, 1)),
+            []providers.Stop{red}, []string{"lines[0]: cta/Red/40381/N not configured"}},
+        {"wrong direction", board(strings.Replace(redLine, `"N"`, `"S"`, 1)),
+            []providers.Stop{red}, []string{"lines[0]: cta/Red/40380/S not configured"}},
+        {"missing stop", board(`{"provider":"cta","line":"Red","direction":"N"}`),
+            []providers.Stop{red}, []string{"lines[0]: provider, line and stop are required"}},
+        {"no upcoming arrivals", board(`{"provider":"cta","line":"Red","stop":"40380","direction":"N","arrivals":[]}`),
+            []providers.Stop{red}, nil},
+        {"missing arrivals", board(`{"provider":"cta","line":"Red","stop":"40380","direction":"N"}`),
+            []providers.Stop{red}, []string{"lines[0]: missing arrivals"}},
+        {"zero arrival", board(strings.Replace(redLine, "2026-01-01T12:05:00Z", "0001-01-01T00:00:00Z", 1)),
+            []providers.Stop{red}, []string{"lines[0].arrivals[0]: zero time"}},
+        {"partial multi-line coverage", board(redLine), []providers.Stop{red, blue},
+            []string{"no line for configured cta/Blue/40490"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := validateCommand([]byte(tt.payload), tt.stops)
+            if len(tt.want) == 0 {
+                if got != "" {
+                    t.Errorf("got %q, want valid", got)
+                }
+                return
+            }
+            for _, w := range tt.want {
+                if !strings.Contains(got, w) {
+                    t.Errorf("got %q, want it to contain %q", got, w)
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,174 @@
+package device
+
+import (
+    "fmt"
+    "strings"
+    "time"
+
+    pahomqtt "github.com/eclipse/paho.mqtt.golang"
+    "github.com/google/uuid"
+)
+
+// Kinds of SecurityFinding.
+const (
+    FindingSubscribeGranted = "subscribe-granted" // broker granted a subscription to another device's topic
+    FindingMessageLeak      = "message-leak"      // a probe received a message meant for another device
+    FindingPublishLeak      = "publish-leak"      // a probe's publish to another device's topic was delivered
+)
+
+// ProbeMarker prefixes the payload a probe publishes on other devices'
+// presence topics, followed by the probe's device ID.
+const ProbeMarker = "loadtest-probe:"
+
+// SecurityFinding is evidence that the broker let one device reach another
+// device's topics.
+type SecurityFinding struct {
+    Timestamp time.Time `json:"timestamp"`
+    DeviceID  string    `json:"deviceId"` // the probing device
+    Kind      string    `json:"kind"`
+    Topic     string    `json:"topic"`
+    Detail    string    `json:"detail,omitempty"`
+}
+
+func (f SecurityFinding) String() string {
+    s := fmt.Sprintf("%s %s on %s", f.DeviceID, f.Kind, f.Topic)
+    if f.Detail != "" {
+        s += ": " + f.Detail
+    }
+    return s
+}
+
+// SetProbePeers makes the device an isolation probe against the given peer
+// device IDs. Call before Run.
+func (d *MockDevice) SetProbePeers(peers []string) {
+    d.probePeers = peers
+}
+
+// IsProbe reports whether the device runs the isolation probe.
+func (d *MockDevice) IsProbe() bool {
+    return len(d.probePeers) > 0
+}
+
+// GetFindings returns a copy of the device's security findings (thread-safe).
+func (d *MockDevice) GetFindings() []SecurityFinding {
+    d.mu.RLock()
+    defer d.mu.RUnlock()
+    cp := make([]SecurityFinding, len(d.Findings))
+    copy(cp, d.Findings)
+    return cp
+}
+
+func (d *MockDevice) addFinding(f SecurityFinding) {
+    d.mu.Lock()
+    d.Findings = append(d.Findings, f)
+    d.mu.Unlock()
+}
+
+// probeIsolation subscribes to the peers' commands topics and to wildcards
+// covering every device, then publishes a marker on each peer's presence
+// topic. Granted subscriptions are findings straight away; messages that
+// arrive on them later are recorded by onProbeMessage. A delivered publish
+// can only be seen by another client, see WatchProbes.
+func (m *mqttClient) probeIsolation() error {
+    if m.client == nil {
+        return fmt.Errorf("mqtt not connected")
+    }
+    topics := make([]string, 0, len(m.device.probePeers)+2)
+    for _, peer := range m.device.probePeers {
+        topics = append(topics, commandsTopic(peer))
+    }
+    topics = append(topics, "/device/+/commands", "device/+/presence")
+
+    for _, topic := range topics {
+        token := m.client.Subscribe(topic, 0, m.onProbeMessage)
+        if !token.WaitTimeout(m.timeouts.MQTTSubscribe) {
+            return fmt.Errorf("probe subscribe %s: timeout", topic)
+        }
+        if err := token.Error(); err != nil {
+            // Refused by dropping the connection; nothing leaked.
+            continue
+        }
+        if qos, ok := token.(*pahomqtt.SubscribeToken).Result()[topic]; ok && qos != 0x80 {
+            m.device.addFinding(SecurityFinding{
+                Timestamp: time.Now(),
+                DeviceID:  m.device.DeviceID,
+                Kind:      FindingSubscribeGranted,
+                Topic:     topic,
+            })
+        }
+    }
+    for _, peer := range m.device.probePeers {
+        m.client.Publish(presenceTopic(peer), 0, false, ProbeMarker+m.device.DeviceID).
+            WaitTimeout(m.timeouts.MQTTSubscribe)
+    }
+    return nil
+}
+
+// onProbeMessage records any message on a probe subscription that was not
+// addressed to the probe itself.
+func (m *mqttClient) onProbeMessage(_ pahomqtt.Client, msg pahomqtt.Message) {
+    id := m.device.DeviceID
+    if msg.Topic() == commandsTopic(id) || msg.Topic() == presenceTopic(id) {
+        return
+    }
+    m.device.addFinding(SecurityFinding{
+        Timestamp: time.Now(),
+        DeviceID:  id,
+        Kind:      FindingMessageLeak,
+        Topic:     msg.Topic(),
+        Detail:    truncatePayload(string(msg.Payload()), 80),
+    })
+}
+
+// WatchProbes connects a privileged observer with the shared broker login in
+// opts and reports every probe marker that reaches another device's presence
+// topic. Call the returned func to disconnect.
+func WatchProbes(opts Options, report func(SecurityFinding)) (stop func(), err error) {
+    broker, err := BrokerURL(opts.MQTTTransport, opts.MQTTHost, opts.MQTTPort, opts.MQTTPath)
+    if err != nil {
+        return nil, err
+    }
+    timeouts := opts.Timeouts.withDefaults()
+    co := pahomqtt.NewClientOptions().
+        AddBroker(broker).
+        SetClientID("loadtest-observer-" + uuid.New().String()).
+        SetUsername(opts.MQTTUsername).
+        SetPassword(opts.MQTTPassword).
+        SetCleanSession(true).
+        SetConnectTimeout(timeouts.MQTTConnect)
+    if opts.MQTTTLS != nil {
+        co.SetTLSConfig(opts.MQTTTLS)
+    }
+    client := pahomqtt.NewClient(co)
+    token := client.Connect()
+    if !token.WaitTimeout(timeouts.MQTTConnect) {
+        return nil, fmt.Errorf("observer connect timeout")
+    }
+    if err := token.Error(); err != nil {
+        return nil, fmt.Errorf("observer connect: %w", err)
+    }
+    token = client.Subscribe("device/+/presence", 0, func(_ pahomqtt.Client, msg pahomqtt.Message) {
+        probe, ok := strings.CutPrefix(string(msg.Payload()), ProbeMarker)
+        if !ok {
+            return
+        }
+        report(SecurityFinding{
+            Timestamp: time.Now(),
+            DeviceID:  probe,
+            Kind:      FindingPublishLeak,
+            Topic:     msg.Topic(),
+        })
+    })
+    if !token.WaitTimeout(timeouts.MQTTSubscribe) || token.Error() != nil {
+        client.Disconnect(250)
+        return nil, fmt.Errorf("observer subscribe failed: %v", token.Error())
+    }
+    return func() { client.Disconnect(250) }, nil
+}
+
+func truncatePayload(s string, max int) string {
+    if len(s) <= max {
+        return s
+    }
+    return s[:max-3] + "..."
+}
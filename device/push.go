@@ -0,0 +1,90 @@
+package device
+
+import (
+    "encoding/json"
+    "strconv"
+    "time"
+)
+
+// Push latency sources, recorded on each MQTTMessage.
+const (
+    LatencyServerTS = "server-ts" // timestamp embedded in the payload
+    LatencyTrigger  = "trigger"   // time since config POST or ForceRefresh
+)
+
+// serverTimestampKeys are the payload fields checked, in order, for the time
+// the server published the message.
+var serverTimestampKeys = []string{"sentAt", "publishedAt", "timestamp", "ts"}
+
+// serverTimestamp extracts a publish time from a JSON payload. Numeric values
+// are treated as Unix milliseconds when large enough, otherwise seconds;
+// strings must be RFC 3339.
+func serverTimestamp(payload []byte) (time.Time, bool) {
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(payload, &fields); err != nil {
+        return time.Time{}, false
+    }
+    for _, k := range serverTimestampKeys {
+        raw, ok := fields[k]
+        if !ok {
+            continue
+        }
+        var s string
+        if err := json.Unmarshal(raw, &s); err == nil {
+            if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+                return t, true
+            }
+            if n, err := strconv.ParseFloat(s, 64); err == nil {
+                return unixTime(n), true
+            }
+            continue
+        }
+        var n float64
+        if err := json.Unmarshal(raw, &n); err == nil && n > 0 {
+            return unixTime(n), true
+        }
+    }
+    return time.Time{}, false
+}
+
+func unixTime(n float64) time.Time {
+    if n > 1e12 {
+        return time.UnixMilli(int64(n))
+    }
+    return time.Unix(0, int64(n*float64(time.Second)))
+}
+
+// markTrigger records the time of an action expected to cause a push.
+func (d *MockDevice) markTrigger(t time.Time) {
+    d.mu.Lock()
+    d.pushTrigger = t
+    d.mu.Unlock()
+}
+
+// markSubscribed is called when the commands subscription is (re)made at
+// t. A trigger still pending from before then, such as the config POST made
+// before connecting, is re-armed at t: the push it caused arrives retained or
+// was missed, and timing the next one from t leaves out connect and subscribe.
+func (d *MockDevice) markSubscribed(t time.Time) {
+    d.mu.Lock()
+    if !d.pushTrigger.IsZero() && d.pushTrigger.Before(t) {
+        d.pushTrigger = t
+    }
+    d.mu.Unlock()
+}
+
+// pushLatency works out how long a message received at now took to arrive.
+// A server timestamp wins when present (subject to clock skew between the
+// server and this host); otherwise the first message after a trigger is
+// measured against it and the trigger is consumed. Must be called with mu held.
+func (d *MockDevice) pushLatency(payload []byte, now time.Time) (time.Duration, string) {
+    if ts, ok := serverTimestamp(payload); ok {
+        return now.Sub(ts), LatencyServerTS
+    }
+    if !d.pushTrigger.IsZero() {
+        lat := now.Sub(d.pushTrigger)
+        d.pushTrigger = time.Time{}
+        return lat, LatencyTrigger
+    }
+    return 0, ""
+}
@@ -0,0 +1,116 @@
+package device
+
+import (
+    "errors"
+    "fmt"
+    "math/rand"
+    "slices"
+    "time"
+)
+
+// RetryPolicy controls how often a failed lifecycle step is retried, the way
+// the firmware retries instead of giving up on the first transient failure.
+type RetryPolicy struct {
+    MaxAttempts int           // total attempts including the first; 0 or 1 disables retries
+    Backoff     time.Duration // delay before the first retry, doubled on each further one
+    MaxBackoff  time.Duration // upper bound on the delay
+    Jitter      float64       // fraction of each delay that is randomised, 0..1
+    JitterSet   bool          // Jitter was given explicitly, so merge keeps even a zero
+    // RetryStatus lists the HTTP statuses worth retrying. Transport errors,
+    // timeouts and MQTT failures are always retryable.
+    RetryStatus []int
+}
+
+// DefaultRetryPolicy is a sensible starting point for Options.Retry. It makes
+// a single attempt; raise MaxAttempts to enable retries.
+var DefaultRetryPolicy = RetryPolicy{
+    MaxAttempts: 1,
+    Backoff:     500 * time.Millisecond,
+    MaxBackoff:  10 * time.Second,
+    Jitter:      0.2,
+    RetryStatus: []int{408, 429, 500, 502, 503, 504},
+}
+
+// Validate reports a policy field that cannot be used.
+func (p RetryPolicy) Validate() error {
+    switch {
+    case p.MaxAttempts < 0:
+        return fmt.Errorf("retry attempts must not be negative, got %d", p.MaxAttempts)
+    case p.Backoff < 0 || p.MaxBackoff < 0:
+        return fmt.Errorf("retry backoff must not be negative")
+    case p.Jitter < 0 || p.Jitter > 1:
+        return fmt.Errorf("retry jitter must be between 0 and 1, got %v", p.Jitter)
+    }
+    return nil
+}
+
+// merge returns p with its zero fields taken from base, so a per-step
+// override only needs the fields that differ. A zero Jitter is kept if
+// JitterSet, so an override can turn jitter off.
+func (p RetryPolicy) merge(base RetryPolicy) RetryPolicy {
+    if p.MaxAttempts == 0 {
+        p.MaxAttempts = base.MaxAttempts
+    }
+    if p.Backoff == 0 {
+        p.Backoff = base.Backoff
+    }
+    if p.MaxBackoff == 0 {
+        p.MaxBackoff = base.MaxBackoff
+    }
+    if p.Jitter == 0 && !p.JitterSet {
+        p.Jitter = base.Jitter
+    }
+    if p.RetryStatus == nil {
+        p.RetryStatus = base.RetryStatus
+    }
+    return p
+}
+
+// delay returns the backoff before the given retry (1 = first retry),
+// drawing jitter from rng.
+func (p RetryPolicy) delay(retry int, rng *rand.Rand) time.Duration {
+    d := p.Backoff
+    for i := 1; i < retry && d < p.MaxBackoff; i++ {
+        d *= 2
+    }
+    if d > p.MaxBackoff {
+        d = p.MaxBackoff
+    }
+    if p.Jitter > 0 {
+        // Spread retries over [d*(1-jitter), d] so devices that failed
+        // together don't retry in lockstep.
+        d -= time.Duration(rng.Float64() * p.Jitter * float64(d))
+    }
+    return d
+}
+
+// retryable reports whether err is worth another attempt under p.
+func (p RetryPolicy) retryable(err error) bool {
+    var se *StatusError
+    if errors.As(err, &se) {
+        return slices.Contains(p.RetryStatus, se.Status)
+    }
+    return true
+}
+
+// StatusError is returned by a lifecycle request the server answered with an
+// unexpected HTTP status.
+type StatusError struct {
+    Op     string
+    Status int
+}
+
+func (e *StatusError) Error() string {
+    return fmt.Sprintf("%s returned %d", e.Op, e.Status)
+}
+
+// retryPolicy returns the policy for the step with the given key.
+func (o *Options) retryPolicy(key string) RetryPolicy {
+    if key == "" {
+        return RetryPolicy{MaxAttempts: 1}
+    }
+    if p, ok := o.StepRetry[key]; ok {
+        return p.merge(o.Retry)
+    }
+    return o.Retry
+}
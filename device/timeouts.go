@@ -0,0 +1,89 @@
+package device
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Timeouts bounds each lifecycle step. A zero field uses the matching
+// DefaultTimeouts value.
+type Timeouts struct {
+    Register      time.Duration // device and user registration
+    Login         time.Duration
+    Link          time.Duration
+    SetConfig     time.Duration
+    GetConfig     time.Duration
+    MQTTCreds     time.Duration // fetching per-device broker credentials
+    MQTTConnect   time.Duration
+    MQTTSubscribe time.Duration
+}
+
+// DefaultTimeouts are generous limits suited to a healthy backend. Config
+// POSTs trigger a live provider fetch on the server, hence the 90s.
+var DefaultTimeouts = Timeouts{
+    Register:      15 * time.Second,
+    Login:         15 * time.Second,
+    Link:          15 * time.Second,
+    SetConfig:     90 * time.Second,
+    GetConfig:     15 * time.Second,
+    MQTTCreds:     15 * time.Second,
+    MQTTConnect:   10 * time.Second,
+    MQTTSubscribe: 5 * time.Second,
+}
+
+// defaultHTTPTimeout applies to requests outside the timed steps
+// (refresh, logout).
+const defaultHTTPTimeout = 15 * time.Second
+
+// StepKeys lists the lifecycle step names accepted by Timeouts.Set and
+// Options.StepRetry.
+var StepKeys = []string{
+    "register", "login", "link", "set-config", "get-config", "mqtt-credentials", "mqtt-connect", "mqtt-subscribe",
+}
+
+func (t *Timeouts) field(step string) *time.Duration {
+    switch step {
+    case "register":
+        return &t.Register
+    case "login":
+        return &t.Login
+    case "link":
+        return &t.Link
+    case "set-config":
+        return &t.SetConfig
+    case "get-config":
+        return &t.GetConfig
+    case "mqtt-credentials":
+        return &t.MQTTCreds
+    case "mqtt-connect":
+        return &t.MQTTConnect
+    case "mqtt-subscribe":
+        return &t.MQTTSubscribe
+    }
+    return nil
+}
+
+// Set sets the timeout of the named step, e.g. "mqtt-connect".
+func (t *Timeouts) Set(step string, d time.Duration) error {
+    f := t.field(step)
+    if f == nil {
+        return fmt.Errorf("unknown timeout step %q (want one of %s)", step, strings.Join(StepKeys, ", "))
+    }
+    if d <= 0 {
+        return fmt.Errorf("timeout for %s must be positive, got %s", step, d)
+    }
+    *f = d
+    return nil
+}
+
+// withDefaults fills zero fields from DefaultTimeouts.
+func (t Timeouts) withDefaults() Timeouts {
+    def := DefaultTimeouts
+    for _, step := range StepKeys {
+        if f := t.field(step); *f == 0 {
+            *f = *def.field(step)
+        }
+    }
+    return t
+}
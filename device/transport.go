@@ -0,0 +1,95 @@
+package device
+
+import (
+    "crypto/tls"
+    "crypto/x509"
+    "fmt"
+    "net"
+    "os"
+    "strconv"
+)
+
+// MQTT transports accepted in Options.MQTTTransport.
+const (
+    TransportTCP = "tcp"
+    TransportSSL = "ssl"
+    TransportWS  = "ws"
+    TransportWSS = "wss"
+)
+
+// DefaultWSPath is the websocket path used when Options.MQTTPath is empty.
+const DefaultWSPath = "/mqtt"
+
+// DefaultMQTTPort returns the conventional broker port for a transport.
+func DefaultMQTTPort(transport string) int {
+    switch transport {
+    case TransportSSL:
+        return 8883
+    case TransportWS:
+        return 80
+    case TransportWSS:
+        return 443
+    default:
+        return 1883
+    }
+}
+
+// BrokerURL builds the paho broker URL for a transport, e.g.
+// "wss://mqtt.example.com:443/mqtt". An empty transport means TCP.
+func BrokerURL(transport, host string, port int, path string) (string, error) {
+    hostPort := net.JoinHostPort(host, strconv.Itoa(port))
+    switch transport {
+    case "", TransportTCP:
+        return "tcp://" + hostPort, nil
+    case TransportSSL:
+        return "ssl://" + hostPort, nil
+    case TransportWS, TransportWSS:
+        if path == "" {
+            path = DefaultWSPath
+        }
+        if path[0] != '/' {
+            path = "/" + path
+        }
+        return transport + "://" + hostPort + path, nil
+    }
+    return "", fmt.Errorf("unknown MQTT transport %q (want tcp, ssl, ws or wss)", transport)
+}
+
+// TLSOptions are the file-based TLS settings for the ssl and wss transports.
+type TLSOptions struct {
+    CAFile             string // PEM bundle to verify the broker; empty uses the system roots
+    CertFile           string // client certificate, for brokers that require mutual TLS
+    KeyFile            string
+    ServerName         string // SNI and verification name; empty uses the broker host
+    InsecureSkipVerify bool
+}
+
+// Config loads the files and builds a tls.Config.
+func (o TLSOptions) Config() (*tls.Config, error) {
+    cfg := &tls.Config{
+        ServerName:         o.ServerName,
+        InsecureSkipVerify: o.InsecureSkipVerify,
+    }
+    if o.CAFile != "" {
+        pem, err := os.ReadFile(o.CAFile)
+        if err != nil {
+            return nil, fmt.Errorf("mqtt ca: %w", err)
+        }
+        pool := x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(pem) {
+            return nil, fmt.Errorf("mqtt ca: no certificates found in %s", o.CAFile)
+        }
+        cfg.RootCAs = pool
+    }
+    if (o.CertFile == "") != (o.KeyFile == "") {
+        return nil, fmt.Errorf("mqtt client certificate and key must be given together")
+    }
+    if o.CertFile != "" {
+        cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+        if err != nil {
+            return nil, fmt.Errorf("mqtt client certificate: %w", err)
+        }
+        cfg.Certificates = []tls.Certificate{cert}
+    }
+    return cfg, nil
+}
@@ -0,0 +1,228 @@
+// Package gtfs derives stop catalogue entries from a GTFS static feed.
+//
+// A feed is a zip of CSV files. Import reads routes.txt, trips.txt,
+// stop_times.txt and stops.txt and yields one entry per distinct
+// (route, stop, direction) that some trip actually serves, weighted by how
+// many scheduled stop times it has.
+package gtfs
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/commute-live/loadtest/providers"
+)
+
+// Options selects what Import takes from a feed and how it labels it.
+type Options struct {
+	Provider   string // catalogue provider key, e.g. "wmata"
+	ProviderID string // CommuteLive provider ID, e.g. "wmata-metro"
+
+	// RouteTypes and RouteIDs keep only matching routes; empty keeps all.
+	// Route types are the GTFS codes, e.g. 0 tram, 1 subway, 2 rail, 3 bus.
+	RouteTypes []int
+	RouteIDs   []string
+
+	// ParentStations reports platforms as their parent station, for
+	// providers that key arrivals by station.
+	ParentStations bool
+
+	// NoDirection leaves Direction empty instead of using the trip's
+	// direction_id, for providers whose stop IDs already encode it.
+	NoDirection bool
+
+	// Weighted sets each entry's weight to its number of scheduled stop
+	// times, so busy stops are picked more often.
+	Weighted bool
+}
+
+// trip is what Import keeps of a trips.txt row.
+type trip struct {
+	route     string
+	direction string
+}
+
+// Import reads the feed at file and returns its catalogue entries, sorted
+// by line, stop and direction.
+func Import(file string, opts Options) ([]providers.Entry, error) {
+	if opts.Provider == "" || opts.ProviderID == "" {
+		return nil, errors.New("provider key and provider ID are required")
+	}
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("open GTFS feed: %w", err)
+	}
+	defer zr.Close()
+	feed := feedFiles(&zr.Reader)
+
+	routes := make(map[string]bool)
+	err = readTable(feed, "routes.txt", []string{"route_id", "route_type"}, func(row map[string]string) error {
+		if len(opts.RouteIDs) > 0 && !slices.Contains(opts.RouteIDs, row["route_id"]) {
+			return nil
+		}
+		if len(opts.RouteTypes) > 0 {
+			t, err := strconv.Atoi(row["route_type"])
+			if err != nil {
+				return fmt.Errorf("route %s: invalid route_type %q", row["route_id"], row["route_type"])
+			}
+			if !slices.Contains(opts.RouteTypes, t) {
+				return nil
+			}
+		}
+		routes[row["route_id"]] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return nil, errors.New("no routes match the filters")
+	}
+
+	trips := make(map[string]trip)
+	err = readTable(feed, "trips.txt", []string{"route_id", "trip_id"}, func(row map[string]string) error {
+		if routes[row["route_id"]] {
+			trips[row["trip_id"]] = trip{route: row["route_id"], direction: row["direction_id"]}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stations := make(map[string]string)
+	if opts.ParentStations {
+		err = readTable(feed, "stops.txt", []string{"stop_id"}, func(row map[string]string) error {
+			if p := row["parent_station"]; p != "" {
+				stations[row["stop_id"]] = p
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	type tuple struct{ line, stop, direction string }
+	counts := make(map[tuple]int)
+	err = readTable(feed, "stop_times.txt", []string{"trip_id", "stop_id"}, func(row map[string]string) error {
+		t, ok := trips[row["trip_id"]]
+		if !ok {
+			return nil
+		}
+		stop := row["stop_id"]
+		if p, ok := stations[stop]; ok {
+			stop = p
+		}
+		k := tuple{line: t.route, stop: stop}
+		if !opts.NoDirection {
+			k.direction = t.direction
+		}
+		counts[k]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(counts) == 0 {
+		return nil, errors.New("no trips of the selected routes stop anywhere")
+	}
+
+	entries := make([]providers.Entry, 0, len(counts))
+	for k, n := range counts {
+		e := providers.Entry{
+			Provider:   opts.Provider,
+			ProviderID: opts.ProviderID,
+			Line:       k.line,
+			StopID:     k.stop,
+			Direction:  k.direction,
+		}
+		if opts.Weighted {
+			e.Weight = float64(n)
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.StopID != b.StopID {
+			return a.StopID < b.StopID
+		}
+		return a.Direction < b.Direction
+	})
+	return entries, nil
+}
+
+// feedFiles indexes a feed's files by base name, since some feeds nest
+// them in a directory.
+func feedFiles(zr *zip.Reader) map[string]*zip.File {
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[path.Base(f.Name)] = f
+	}
+	return files
+}
+
+// readTable streams a feed file, calling fn with each row keyed by column
+// name. It fails if the file or any required column is missing.
+func readTable(feed map[string]*zip.File, name string, required []string, fn func(row map[string]string) error) error {
+	f, ok := feed[name]
+	if !ok {
+		return fmt.Errorf("feed has no %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer rc.Close()
+
+	r := csv.NewReader(rc)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("%s: read header: %w", name, err)
+	}
+	cols := make([]string, len(header))
+	for i, h := range header {
+		if i == 0 {
+			h = strings.TrimPrefix(h, "\ufeff") // byte order mark
+		}
+		cols[i] = strings.TrimSpace(h)
+	}
+	for _, c := range required {
+		if !slices.Contains(cols, c) {
+			return fmt.Errorf("%s: missing column %s", name, c)
+		}
+	}
+
+	row := make(map[string]string, len(cols))
+	for line := 2; ; line++ {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		clear(row)
+		for i, v := range rec {
+			if i < len(cols) {
+				row[cols[i]] = strings.TrimSpace(v)
+			}
+		}
+		if err := fn(row); err != nil {
+			return fmt.Errorf("%s line %d: %w", name, line, err)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package manifest records the identities a load test run creates, so that a
+// crashed or finished run can later be inspected, cleaned up or resumed.
+//
+// A manifest is a JSON Lines file: one "run" record followed by one "device"
+// record per created device and, if the run ended cleanly, a "finished"
+// record. Each record is flushed to disk as soon as it is written.
+package manifest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/commute-live/loadtest/providers"
+)
+
+// Record types.
+const (
+	typeRun      = "run"
+	typeDevice   = "device"
+	typeFinished = "finished"
+)
+
+// Manifest is the parsed content of a manifest file.
+type Manifest struct {
+	RunID      string
+	StartedAt  time.Time
+	FinishedAt time.Time // zero if the run did not finish cleanly
+	ServerURL  string
+	Devices    []Device
+}
+
+// Device is one created device identity and its stop assignment.
+type Device struct {
+	DeviceID string           `json:"deviceId"`
+	Email    string           `json:"email"`
+	Password string           `json:"password"`
+	Stop     providers.Stop   `json:"stop"`            // the first configured stop
+	Extra    []providers.Stop `json:"extra,omitempty"` // any further configured stops
+}
+
+// Stops returns every stop the device configured, Stop first.
+func (d Device) Stops() []providers.Stop {
+	return append([]providers.Stop{d.Stop}, d.Extra...)
+}
+
+type record struct {
+	Type       string     `json:"type"`
+	RunID      string     `json:"runId,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	ServerURL  string     `json:"serverUrl,omitempty"`
+	*Device
+}
+
+// Writer appends records to a manifest file.
+type Writer struct {
+	mu   sync.Mutex
+	path string
+}
+
+// Path returns the default manifest location for a run inside dir.
+func Path(dir, runID string) string {
+	return filepath.Join(dir, runID+".jsonl")
+}
+
+// Create starts a new manifest at path with the run header. The file is only
+// readable by the current user since it holds account passwords.
+func Create(path, runID, serverURL string, startedAt time.Time) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create manifest dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("create manifest: %w", err)
+	}
+	f.Close()
+	w := &Writer{path: path}
+	if err := w.append(record{Type: typeRun, RunID: runID, StartedAt: &startedAt, ServerURL: serverURL}); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Path returns the file the writer appends to.
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// AddDevice records a created device.
+func (w *Writer) AddDevice(d Device) error {
+	return w.append(record{Type: typeDevice, Device: &d})
+}
+
+// Finish marks the run as cleanly finished.
+func (w *Writer) Finish(at time.Time) error {
+	return w.append(record{Type: typeFinished, FinishedAt: &at})
+}
+
+// append opens the file for every record so a crash never leaves buffered
+// data behind.
+func (w *Writer) append(rec record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open manifest: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return f.Sync()
+}
+
+// Load reads a manifest file. A truncated last line, as left by a crash
+// mid-write, is ignored.
+func Load(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open manifest: %w", err)
+	}
+	defer f.Close()
+
+	m := &Manifest{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	var pending error
+	for sc.Scan() {
+		line++
+		if pending != nil {
+			return nil, pending
+		}
+		var rec record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			pending = fmt.Errorf("%s:%d: %w", path, line, err)
+			continue
+		}
+		switch rec.Type {
+		case typeRun:
+			m.RunID = rec.RunID
+			m.ServerURL = rec.ServerURL
+			if rec.StartedAt != nil {
+				m.StartedAt = *rec.StartedAt
+			}
+		case typeDevice:
+			if rec.Device != nil {
+				m.Devices = append(m.Devices, *rec.Device)
+			}
+		case typeFinished:
+			if rec.FinishedAt != nil {
+				m.FinishedAt = *rec.FinishedAt
+			}
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown record type %q", path, line, rec.Type)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	if m.RunID == "" {
+		return nil, fmt.Errorf("%s: missing run record", path)
+	}
+	return m, nil
+}
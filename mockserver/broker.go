@@ -0,0 +1,130 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/commute-live/loadtest/device"
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// ArrivalPayload is the message published on /device/{id}/commands.
+type ArrivalPayload struct {
+	SentAt *time.Time     `json:"sentAt,omitempty"` // nil with Config.NoTimestamps
+	Lines  []ArrivalBoard `json:"lines"`
+}
+
+// ArrivalBoard holds the upcoming arrivals for one configured line.
+type ArrivalBoard struct {
+	Provider  string      `json:"provider"`
+	Line      string      `json:"line"`
+	Stop      string      `json:"stop"`
+	Direction string      `json:"direction"`
+	Arrivals  []time.Time `json:"arrivals"`
+}
+
+// CommandsTopic returns the topic a device receives arrival pushes on.
+func CommandsTopic(deviceID string) string {
+	return "/device/" + deviceID + "/commands"
+}
+
+// publishArrivals sends a fake arrival board for every configured line.
+func (s *Server) publishArrivals(d *deviceRecord) {
+	now := time.Now()
+	s.mu.Lock()
+	payload := ArrivalPayload{}
+	if !s.cfg.NoTimestamps {
+		payload.SentAt = &now
+	}
+	for _, l := range d.Lines {
+		board := ArrivalBoard{Provider: l.Provider, Line: l.Line, Stop: l.Stop, Direction: l.Direction}
+		next := now
+		for i := 0; i < 3; i++ {
+			next = next.Add(time.Duration(2+s.rng.Intn(8)) * time.Minute)
+			board.Arrivals = append(board.Arrivals, next.Truncate(time.Second))
+		}
+		payload.Lines = append(payload.Lines, board)
+	}
+	topic := CommandsTopic(d.ID)
+	s.mu.Unlock()
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		s.log.Error("marshal arrivals", "error", err)
+		return
+	}
+	// Retained, so a device that (re)subscribes after its config was set
+	// still gets the current board, as displays do after a reboot.
+	if err := s.broker.Publish(topic, b, true, 0); err != nil {
+		s.log.Error("publish arrivals", "topic", topic, "error", err)
+	}
+}
+
+// authHook checks broker logins and confines per-device logins to their
+// own topics. The shared login may use any topic.
+type authHook struct {
+	mqtt.HookBase
+	srv *Server
+}
+
+func (h *authHook) ID() string { return "mockserver-auth" }
+
+func (h *authHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{mqtt.OnConnectAuthenticate, mqtt.OnACLCheck}, []byte{b})
+}
+
+func (h *authHook) OnConnectAuthenticate(cl *mqtt.Client, pk packets.Packet) bool {
+	cfg := h.srv.cfg
+	user, pass := string(pk.Connect.Username), string(pk.Connect.Password)
+	if !cfg.RequireDeviceAuth {
+		if cfg.MQTTUsername == "" {
+			return true
+		}
+		if user == cfg.MQTTUsername && pass == cfg.MQTTPassword {
+			return true
+		}
+	}
+	return h.srv.deviceLogin(user, pass)
+}
+
+func (h *authHook) OnACLCheck(cl *mqtt.Client, topic string, write bool) bool {
+	id := string(cl.Properties.Username)
+	if !h.srv.isDeviceLogin(id) {
+		return true
+	}
+	if write {
+		return topic == "device/"+id+"/presence"
+	}
+	return topic == CommandsTopic(id)
+}
+
+// deviceLogin checks a per-device login: the username is a registered device
+// ID and the password is the one issued to it or derived from the shared
+// broker password.
+func (s *Server) deviceLogin(user, pass string) bool {
+	s.mu.Lock()
+	d, ok := s.devices[user]
+	issued := ""
+	if ok {
+		issued = d.MQTTPass
+	}
+	s.mu.Unlock()
+	if !ok || pass == "" {
+		return false
+	}
+	return pass == issued || pass == device.DeriveMQTTPassword(s.cfg.MQTTPassword, user)
+}
+
+// isDeviceLogin reports whether a connected client logged in as a device
+// rather than with the shared login.
+func (s *Server) isDeviceLogin(user string) bool {
+	if user == "" || user == s.cfg.MQTTUsername {
+		return false
+	}
+	s.mu.Lock()
+	_, ok := s.devices[user]
+	s.mu.Unlock()
+	return ok
+}
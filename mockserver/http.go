@@ -0,0 +1,305 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const sessionCookie = "session"
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /device/register", s.handleRegisterDevice)
+	mux.HandleFunc("POST /user/register", s.handleRegisterUser)
+	mux.HandleFunc("POST /auth/login", s.handleLogin)
+	mux.HandleFunc("POST /auth/logout", s.handleLogout)
+	mux.HandleFunc("POST /user/device/link", s.withSession(s.handleLink))
+	mux.HandleFunc("POST /device/{id}/config", s.withSession(s.handleSetConfig))
+	mux.HandleFunc("GET /device/{id}/config", s.withSession(s.handleGetConfig))
+	mux.HandleFunc("POST /refresh/{id}", s.withSession(s.handleRefresh))
+	mux.HandleFunc("GET /device/{id}/mqtt-credentials", s.withSession(s.handleMQTTCredentials))
+	mux.HandleFunc("DELETE /loadtest/device/{id}", s.handleDeleteDevice)
+	mux.HandleFunc("DELETE /loadtest/user/{email}", s.handleDeleteUser)
+	return s.middleware(mux)
+}
+
+// middleware applies the secret key check and the latency/error knobs to
+// every request.
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.SecretKey != "" && r.Header.Get("X-Loadtest-Key") != s.cfg.SecretKey {
+			writeError(w, http.StatusUnauthorized, "invalid loadtest key")
+			return
+		}
+
+		s.mu.Lock()
+		delay := s.cfg.Latency
+		if s.cfg.Jitter > 0 {
+			delay += time.Duration(s.rng.Int63n(int64(s.cfg.Jitter)))
+		}
+		fail := s.cfg.ErrorRate > 0 && s.rng.Float64() < s.cfg.ErrorRate
+		s.mu.Unlock()
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fail {
+			writeError(w, http.StatusServiceUnavailable, "injected failure")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withSession rejects requests without a valid session cookie and passes the
+// logged-in email to h.
+func (s *Server) withSession(h func(http.ResponseWriter, *http.Request, string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie(sessionCookie)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "not logged in")
+			return
+		}
+		s.mu.Lock()
+		email, ok := s.sessions[c.Value]
+		s.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "invalid session")
+			return
+		}
+		h(w, r, email)
+	}
+}
+
+func (s *Server) handleRegisterDevice(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ID       string `json:"id"`
+		Timezone string `json:"timezone"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	if body.ID == "" {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.devices[body.ID]; ok {
+		// Real devices re-register on every boot.
+		writeJSON(w, http.StatusOK, map[string]string{"id": body.ID})
+		return
+	}
+	s.devices[body.ID] = &deviceRecord{ID: body.ID, Timezone: body.Timezone}
+	writeJSON(w, http.StatusCreated, map[string]string{"id": body.ID})
+}
+
+func (s *Server) handleRegisterUser(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	if body.Email == "" || body.Password == "" {
+		writeError(w, http.StatusBadRequest, "email and password are required")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[body.Email]; ok {
+		writeError(w, http.StatusConflict, "user already exists")
+		return
+	}
+	s.users[body.Email] = body.Password
+	writeJSON(w, http.StatusCreated, map[string]string{"email": body.Email})
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	pw, ok := s.users[body.Email]
+	if !ok || pw != body.Password {
+		s.mu.Unlock()
+		writeError(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+	token := uuid.New().String()
+	s.sessions[token] = body.Email
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: token, Path: "/", HttpOnly: true})
+	writeJSON(w, http.StatusOK, map[string]string{"email": body.Email})
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		s.mu.Lock()
+		delete(s.sessions, c.Value)
+		s.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleLink(w http.ResponseWriter, r *http.Request, email string) {
+	var body struct {
+		DeviceID string `json:"deviceId"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[body.DeviceID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "device not found")
+		return
+	}
+	if d.Owner != "" && d.Owner != email {
+		writeError(w, http.StatusConflict, "device linked to another user")
+		return
+	}
+	d.Owner = email
+	writeJSON(w, http.StatusOK, map[string]string{"deviceId": d.ID})
+}
+
+// ownedDevice looks up the {id} path device and checks it belongs to email.
+// It writes the error response itself and returns nil on failure.
+func (s *Server) ownedDevice(w http.ResponseWriter, r *http.Request, email string) *deviceRecord {
+	s.mu.Lock()
+	d, ok := s.devices[r.PathValue("id")]
+	owned := ok && d.Owner == email
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "device not found")
+		return nil
+	}
+	if !owned {
+		writeError(w, http.StatusForbidden, "device not linked to this user")
+		return nil
+	}
+	return d
+}
+
+func (s *Server) handleSetConfig(w http.ResponseWriter, r *http.Request, email string) {
+	d := s.ownedDevice(w, r, email)
+	if d == nil {
+		return
+	}
+	var body struct {
+		Lines []Line `json:"lines"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	if len(body.Lines) == 0 {
+		writeError(w, http.StatusBadRequest, "at least one line is required")
+		return
+	}
+	s.mu.Lock()
+	d.Lines = body.Lines
+	s.mu.Unlock()
+
+	// The real server fetches provider data before answering; push the
+	// resulting board straight away.
+	s.publishArrivals(d)
+	writeJSON(w, http.StatusOK, map[string]any{"lines": body.Lines})
+}
+
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request, email string) {
+	d := s.ownedDevice(w, r, email)
+	if d == nil {
+		return
+	}
+	s.mu.Lock()
+	lines := append([]Line(nil), d.Lines...)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"lines": lines})
+}
+
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request, email string) {
+	d := s.ownedDevice(w, r, email)
+	if d == nil {
+		return
+	}
+	s.publishArrivals(d)
+	writeJSON(w, http.StatusOK, map[string]string{"deviceId": d.ID})
+}
+
+// handleMQTTCredentials issues the device's own broker login, generating the
+// password on first use.
+func (s *Server) handleMQTTCredentials(w http.ResponseWriter, r *http.Request, email string) {
+	d := s.ownedDevice(w, r, email)
+	if d == nil {
+		return
+	}
+	s.mu.Lock()
+	if d.MQTTPass == "" {
+		d.MQTTPass = uuid.New().String()
+	}
+	pass := d.MQTTPass
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{"username": d.ID, "password": pass})
+}
+
+func (s *Server) handleDeleteDevice(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.PathValue("id")
+	if _, ok := s.devices[id]; !ok {
+		writeError(w, http.StatusNotFound, "device not found")
+		return
+	}
+	delete(s.devices, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	email := r.PathValue("email")
+	if _, ok := s.users[email]; !ok {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	delete(s.users, email)
+	for token, e := range s.sessions {
+		if e == email {
+			delete(s.sessions, token)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,210 @@
+// Package mockserver implements a fake CommuteLive backend — the HTTP API the
+// mock devices call plus an embedded MQTT broker — so the load test can run
+// without a staging environment.
+package mockserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+// Config holds the listen addresses and fault-injection knobs for the server.
+type Config struct {
+	HTTPAddr     string      // e.g. ":8080"; ":0" picks a free port
+	MQTTAddr     string      // e.g. ":1883"; ":0" picks a free port
+	MQTTWSAddr   string      // MQTT over websockets, e.g. ":8083"; empty disables it
+	TLS          *tls.Config // if set, the MQTT listeners serve ssl:// and wss://
+	SecretKey    string      // expected X-Loadtest-Key; empty disables the check
+	MQTTUsername string      // shared broker login; empty allows anonymous clients
+	MQTTPassword string
+	// RequireDeviceAuth rejects the shared broker login, so only per-device
+	// credentials (issued or derived from MQTTPassword) can connect.
+	RequireDeviceAuth bool
+
+	Latency      time.Duration // added to every HTTP response
+	Jitter       time.Duration // random extra latency in [0, Jitter)
+	ErrorRate    float64       // fraction (0..1) of requests answered with 503
+	PushInterval time.Duration // periodic arrival pushes per configured device; 0 = only on config/refresh
+	NoTimestamps bool          // leave sentAt out of pushes, so devices time them from their own triggers
+
+	Logger *slog.Logger // nil = discard
+}
+
+// Server is a running fake backend.
+type Server struct {
+	cfg    Config
+	log    *slog.Logger
+	broker *mqtt.Server
+	http   *http.Server
+
+	httpLn net.Listener
+	mqttLn net.Listener
+
+	mu       sync.Mutex
+	devices  map[string]*deviceRecord
+	users    map[string]string // email → password
+	sessions map[string]string // session token → email
+	rng      *rand.Rand
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+type deviceRecord struct {
+	ID       string
+	Timezone string
+	Owner    string // email of the linked user
+	MQTTPass string // issued by /device/{id}/mqtt-credentials
+	Lines    []Line
+}
+
+// Line is one entry of a device config, as sent by the device.
+type Line struct {
+	Provider  string `json:"provider"`
+	Line      string `json:"line"`
+	Stop      string `json:"stop"`
+	Direction string `json:"direction"`
+}
+
+// New creates a server; call Start to begin listening.
+func New(cfg Config) *Server {
+	log := cfg.Logger
+	if log == nil {
+		log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return &Server{
+		cfg:      cfg,
+		log:      log,
+		devices:  make(map[string]*deviceRecord),
+		users:    make(map[string]string),
+		sessions: make(map[string]string),
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start binds both listeners and serves in the background.
+func (s *Server) Start() error {
+	var err error
+	s.mqttLn, err = net.Listen("tcp", s.cfg.MQTTAddr)
+	if err != nil {
+		return fmt.Errorf("mqtt listen: %w", err)
+	}
+	if s.cfg.TLS != nil {
+		s.mqttLn = tls.NewListener(s.mqttLn, s.cfg.TLS)
+	}
+	s.httpLn, err = net.Listen("tcp", s.cfg.HTTPAddr)
+	if err != nil {
+		s.mqttLn.Close()
+		return fmt.Errorf("http listen: %w", err)
+	}
+
+	s.broker = mqtt.New(&mqtt.Options{InlineClient: true, Logger: s.log})
+	// fail releases everything bound so far, including a websocket
+	// listener the broker already opened.
+	fail := func(err error) error {
+		s.broker.Close()
+		s.mqttLn.Close()
+		s.httpLn.Close()
+		return err
+	}
+	if err := s.broker.AddHook(&authHook{srv: s}, nil); err != nil {
+		return fail(fmt.Errorf("mqtt hook: %w", err))
+	}
+	if err := s.broker.AddListener(listeners.NewNet("mock", s.mqttLn)); err != nil {
+		return fail(fmt.Errorf("mqtt listener: %w", err))
+	}
+	if s.cfg.MQTTWSAddr != "" {
+		ws := listeners.NewWebsocket(listeners.Config{ID: "mock-ws", Address: s.cfg.MQTTWSAddr, TLSConfig: s.cfg.TLS})
+		if err := s.broker.AddListener(ws); err != nil {
+			return fail(fmt.Errorf("mqtt websocket listener: %w", err))
+		}
+	}
+	if err := s.broker.Serve(); err != nil {
+		return fail(fmt.Errorf("mqtt serve: %w", err))
+	}
+
+	s.http = &http.Server{Handler: s.routes()}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.http.Serve(s.httpLn); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("http serve", "error", err)
+		}
+	}()
+
+	if s.cfg.PushInterval > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.pushLoop()
+		}()
+	}
+	return nil
+}
+
+// Close stops both listeners and waits for background goroutines.
+func (s *Server) Close() error {
+	select {
+	case <-s.stopCh:
+		return nil
+	default:
+		close(s.stopCh)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	httpErr := s.http.Shutdown(ctx)
+	mqttErr := s.broker.Close()
+	s.wg.Wait()
+	return errors.Join(httpErr, mqttErr)
+}
+
+// HTTPURL returns the base URL of the HTTP API.
+func (s *Server) HTTPURL() string {
+	return "http://" + s.httpLn.Addr().String()
+}
+
+// MQTTAddr returns a host and port clients can dial to reach the broker. A
+// wildcard listen address is reported as 127.0.0.1.
+func (s *Server) MQTTAddr() (string, int) {
+	addr := s.mqttLn.Addr().(*net.TCPAddr)
+	if addr.IP.IsUnspecified() {
+		return "127.0.0.1", addr.Port
+	}
+	return addr.IP.String(), addr.Port
+}
+
+func (s *Server) pushLoop() {
+	ticker := time.NewTicker(s.cfg.PushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			var configured []*deviceRecord
+			for _, d := range s.devices {
+				if len(d.Lines) > 0 {
+					configured = append(configured, d)
+				}
+			}
+			s.mu.Unlock()
+			for _, d := range configured {
+				s.publishArrivals(d)
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package mockserver
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/commute-live/loadtest/device"
+	"github.com/commute-live/loadtest/providers"
+)
+
+// startDevice runs one mock device with opts against a server started with
+// cfg and waits until it is active and has received at least n messages.
+func startDevice(t *testing.T, cfg Config, opts device.Options, n int) *device.MockDevice {
+	t.Helper()
+	cfg.HTTPAddr, cfg.MQTTAddr, cfg.SecretKey = "127.0.0.1:0", "127.0.0.1:0", "k"
+	srv := New(cfg)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	stop, ok := providers.PickStop("cta", rand.New(rand.NewSource(1)))
+	if !ok {
+		t.Fatal("no cta stops")
+	}
+	opts.ServerURL, opts.SecretKey = srv.HTTPURL(), "k"
+	opts.MQTTHost, opts.MQTTPort = srv.MQTTAddr()
+	d := device.New(opts, device.NewIdentity(), []providers.Stop{stop})
+	d.SetIsolationPeer("loadtest-peer")
+
+	events := make(chan device.Event, 64)
+	go d.Run(events)
+	t.Cleanup(func() {
+		d.Shutdown()
+		d.Wait()
+	})
+
+	deadline := time.After(5 * time.Second)
+	for !d.ReachedActive() || d.GetMQTTCount() < n {
+		if d.Failed() {
+			t.Fatalf("device failed: %s", d.GetErrorMsg())
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timed out in state %s with %d messages", d.GetState(), d.GetMQTTCount())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return d
+}
+
+// TestDeviceLifecycle runs a device from registration through to periodic
+// arrival pushes on its commands topic.
+func TestDeviceLifecycle(t *testing.T) {
+	d := startDevice(t, Config{PushInterval: 50 * time.Millisecond}, device.Options{ValidatePayloads: true}, 2)
+
+	for _, step := range []struct{ method, endpoint string }{
+		{"POST", "/device/register"},
+		{"POST", "/user/device/link"},
+		{"POST", "/device/{id}/config"},
+	} {
+		if !httpOK(d.GetHTTPLog(), step.method, step.endpoint) {
+			t.Errorf("no successful %s %s", step.method, step.endpoint)
+		}
+	}
+	msgs := d.GetMQTTMsgs()
+	pushed := false
+	for _, m := range msgs {
+		if m.Invalid != "" {
+			t.Errorf("invalid payload on %s: %s", m.Topic, m.Invalid)
+		}
+		if !m.Retained {
+			pushed = true
+		}
+	}
+	if !pushed {
+		t.Errorf("got %d retained messages but no periodic push", len(msgs))
+	}
+}
+
+// TestTriggerLatency checks that without server timestamps the first push
+// after the config POST is timed from the device's own trigger.
+func TestTriggerLatency(t *testing.T) {
+	d := startDevice(t, Config{PushInterval: 50 * time.Millisecond, NoTimestamps: true}, device.Options{}, 2)
+
+	var sources []string
+	for _, m := range d.GetMQTTMsgs() {
+		if m.Retained {
+			continue
+		}
+		sources = append(sources, m.LatencySource)
+		if m.LatencySource == device.LatencyTrigger && (m.Latency <= 0 || m.Latency > time.Second) {
+			t.Errorf("trigger latency %v, want within the push interval", m.Latency)
+		}
+	}
+	if len(sources) == 0 || sources[0] != device.LatencyTrigger {
+		t.Errorf("push latency sources %q, want %q first", sources, device.LatencyTrigger)
+	}
+}
+
+// TestCheckIsolation runs the isolation check against the shared login,
+// which may subscribe anywhere, and against derived per-device logins,
+// which the broker confines to their own topics.
+func TestCheckIsolation(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     string
+		findings int
+	}{
+		{"shared login", device.MQTTAuthShared, 1},
+		{"per-device login", device.MQTTAuthDerived, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := startDevice(t, Config{MQTTUsername: "u", MQTTPassword: "p"}, device.Options{
+				MQTTUsername:   "u",
+				MQTTPassword:   "p",
+				MQTTAuth:       tt.auth,
+				CheckIsolation: true,
+			}, 0)
+			findings := d.GetFindings()
+			if len(findings) != tt.findings {
+				t.Fatalf("got findings %v, want %d", findings, tt.findings)
+			}
+			for _, f := range findings {
+				if f.Kind != device.FindingSubscribeGranted || f.Topic != "/device/loadtest-peer/commands" {
+					t.Errorf("unexpected finding %v", f)
+				}
+			}
+		})
+	}
+}
+
+func TestMQTTAddrWildcard(t *testing.T) {
+	srv := New(Config{HTTPAddr: "127.0.0.1:0", MQTTAddr: ":0"})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer srv.Close()
+	if host, port := srv.MQTTAddr(); host != "127.0.0.1" || port == 0 {
+		t.Errorf("MQTTAddr() = %s:%d, want 127.0.0.1 and a bound port", host, port)
+	}
+}
+
+func httpOK(log []device.HTTPLogEntry, method, endpoint string) bool {
+	for _, e := range log {
+		if e.Method == method && e.Endpoint == endpoint && e.OK {
+			return true
+		}
+	}
+	return false
+}
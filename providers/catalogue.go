@@ -0,0 +1,228 @@
+package providers
+
+import (
+    "bytes"
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// Catalogue modes for UseCatalogue.
+const (
+    CatalogueMerge   = "merge"   // add file stops to the built-ins
+    CatalogueReplace = "replace" // use only the file's stops
+)
+
+// Entry is one stop in a catalogue file. Weight makes PickStop choose the
+// stop proportionally more often; zero means 1.
+type Entry struct {
+    Provider   string  `yaml:"provider" json:"provider"`
+    ProviderID string  `yaml:"providerId" json:"providerId"`
+    Line       string  `yaml:"line" json:"line"`
+    StopID     string  `yaml:"stopId" json:"stopId"`
+    Direction  string  `yaml:"direction" json:"direction"`
+    Weight     float64 `yaml:"weight,omitempty" json:"weight,omitempty"`
+}
+
+// Stop returns the entry as a device stop assignment.
+func (e Entry) Stop() Stop {
+    return Stop{Provider: e.Provider, ProviderID: e.ProviderID, Line: e.Line, StopID: e.StopID, Direction: e.Direction}
+}
+
+func (e Entry) weight() float64 {
+    if e.Weight == 0 {
+        return 1
+    }
+    return e.Weight
+}
+
+// key identifies a stop within a catalogue; two entries with the same key
+// are the same stop.
+func (e Entry) key() string {
+    return strings.Join([]string{e.Provider, e.ProviderID, e.Line, e.StopID, e.Direction}, "\x00")
+}
+
+// csvColumns is the header a CSV catalogue must start with; weight may be
+// left out.
+var csvColumns = []string{"provider", "providerId", "line", "stopId", "direction", "weight"}
+
+// LoadCatalogue reads and validates a stop catalogue. The format follows
+// the extension: .csv, or .json/.yaml/.yml for a list of entries.
+func LoadCatalogue(path string) ([]Entry, error) {
+    b, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("read stop catalogue: %w", err)
+    }
+    var entries []Entry
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".csv":
+        entries, err = parseCSV(b)
+    case ".json", ".yaml", ".yml":
+        dec := yaml.NewDecoder(bytes.NewReader(b))
+        dec.KnownFields(true)
+        if err = dec.Decode(&entries); errors.Is(err, io.EOF) {
+            err = nil
+        }
+    default:
+        return nil, fmt.Errorf("%s: unknown stop catalogue format (want .csv, .json, .yaml or .yml)", path)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    if err := ValidateCatalogue(entries); err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    return entries, nil
+}
+
+func parseCSV(b []byte) ([]Entry, error) {
+    r := csv.NewReader(bytes.NewReader(b))
+    r.FieldsPerRecord = -1
+    r.TrimLeadingSpace = true
+    rows, err := r.ReadAll()
+    if err != nil {
+        return nil, err
+    }
+    if len(rows) == 0 {
+        return nil, nil
+    }
+    header := rows[0]
+    if len(header) < len(csvColumns)-1 || len(header) > len(csvColumns) {
+        return nil, fmt.Errorf("header must be %s", strings.Join(csvColumns, ","))
+    }
+    for i, name := range header {
+        if strings.TrimSpace(name) != csvColumns[i] {
+            return nil, fmt.Errorf("header column %d is %q, want %q", i+1, name, csvColumns[i])
+        }
+    }
+    entries := make([]Entry, 0, len(rows)-1)
+    for i, row := range rows[1:] {
+        line := i + 2
+        if len(row) != len(header) {
+            return nil, fmt.Errorf("line %d: want %d fields, got %d", line, len(header), len(row))
+        }
+        e := Entry{Provider: row[0], ProviderID: row[1], Line: row[2], StopID: row[3], Direction: row[4]}
+        if len(row) > 5 && strings.TrimSpace(row[5]) != "" {
+            w, err := strconv.ParseFloat(strings.TrimSpace(row[5]), 64)
+            if err != nil {
+                return nil, fmt.Errorf("line %d: invalid weight %q", line, row[5])
+            }
+            e.Weight = w
+        }
+        entries = append(entries, e)
+    }
+    return entries, nil
+}
+
+// ValidateCatalogue checks every entry and returns every problem found.
+func ValidateCatalogue(entries []Entry) error {
+    var errs []error
+    seen := make(map[string]int)
+    for i, e := range entries {
+        add := func(format string, args ...any) {
+            errs = append(errs, fmt.Errorf("stop %d: "+format, append([]any{i + 1}, args...)...))
+        }
+        if e.Provider == "" || e.ProviderID == "" || e.Line == "" || e.StopID == "" {
+            add("provider, providerId, line and stopId are required")
+            continue
+        }
+        // Provider keys are used in "key=percent,..." distributions.
+        if strings.ContainsAny(e.Provider, "=, ") {
+            add("provider %q must not contain '=', ',' or spaces", e.Provider)
+        }
+        if e.Weight < 0 {
+            add("weight must not be negative, got %v", e.Weight)
+        }
+        if j, ok := seen[e.key()]; ok {
+            add("duplicate of stop %d", j+1)
+        }
+        seen[e.key()] = i
+    }
+    if len(entries) == 0 {
+        errs = append(errs, errors.New("no stops"))
+    }
+    return errors.Join(errs...)
+}
+
+// UseCatalogue makes entries the stops PickStop and ValidProviders draw
+// from: added to the built-in stops with CatalogueMerge, where an entry for
+// a built-in stop overrides its weight, or instead of them with
+// CatalogueReplace. It is meant to be called once at startup.
+func UseCatalogue(entries []Entry, mode string) error {
+    if err := ValidateCatalogue(entries); err != nil {
+        return err
+    }
+    var next []Entry
+    switch mode {
+    case CatalogueMerge, "":
+        next = append(next, catalogue...)
+    case CatalogueReplace:
+    default:
+        return fmt.Errorf("unknown stop catalogue mode %q (want merge or replace)", mode)
+    }
+    index := make(map[string]int, len(next))
+    for i, e := range next {
+        index[e.key()] = i
+    }
+    for _, e := range entries {
+        if i, ok := index[e.key()]; ok {
+            next[i] = e
+            continue
+        }
+        index[e.key()] = len(next)
+        next = append(next, e)
+    }
+    catalogue = next
+    byProvider = indexCatalogue(next)
+    return nil
+}
+
+// Catalogue returns a copy of the stops currently in use.
+func Catalogue() []Entry {
+    return append([]Entry(nil), catalogue...)
+}
+
+// WriteCatalogue writes entries in the format LoadCatalogue expects for
+// path's extension.
+func WriteCatalogue(path string, entries []Entry) error {
+    var b bytes.Buffer
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".csv":
+        w := csv.NewWriter(&b)
+        _ = w.Write(csvColumns)
+        for _, e := range entries {
+            weight := ""
+            if e.Weight != 0 {
+                weight = strconv.FormatFloat(e.Weight, 'f', -1, 64)
+            }
+            _ = w.Write([]string{e.Provider, e.ProviderID, e.Line, e.StopID, e.Direction, weight})
+        }
+        w.Flush()
+        if err := w.Error(); err != nil {
+            return err
+        }
+    case ".json":
+        out, err := json.MarshalIndent(entries, "", "  ")
+        if err != nil {
+            return err
+        }
+        b.Write(append(out, '\n'))
+    case ".yaml", ".yml":
+        out, err := yaml.Marshal(entries)
+        if err != nil {
+            return err
+        }
+        b.Write(out)
+    default:
+        return fmt.Errorf("%s: unknown stop catalogue format (want .csv, .json, .yaml or .yml)", path)
+    }
+    return os.WriteFile(path, b.Bytes(), 0o644)
+}
@@ -1,95 +1,188 @@
 package providers
 
-import "math/rand"
+import (
+    "math/rand"
+    "sort"
+)
 
 // Stop represents a single transit stop assignment.
 type Stop struct {
-    Provider  string
-    ProviderID string
-    Line      string
-    StopID    string
-    Direction string
+    Provider   string `json:"provider"`
+    ProviderID string `json:"providerId"`
+    Line       string `json:"line"`
+    StopID     string `json:"stopId"`
+    Direction  string `json:"direction"`
 }
 
-// curated stop lists per provider
-var stopsByProvider = map[string][]Stop{
-    "mta": {
-        {Provider: "mta", ProviderID: "mta-subway", Line: "A", StopID: "A19N", Direction: ""},
-        {Provider: "mta", ProviderID: "mta-subway", Line: "A", StopID: "A19S", Direction: ""},
-        {Provider: "mta", ProviderID: "mta-subway", Line: "L", StopID: "L03N", Direction: ""},
-        {Provider: "mta", ProviderID: "mta-subway", Line: "L", StopID: "L03S", Direction: ""},
-        {Provider: "mta", ProviderID: "mta-subway", Line: "1", StopID: "127N", Direction: ""},
-        {Provider: "mta", ProviderID: "mta-subway", Line: "1", StopID: "127S", Direction: ""},
-        {Provider: "mta", ProviderID: "mta-subway", Line: "N", StopID: "N02N", Direction: ""},
-        {Provider: "mta", ProviderID: "mta-subway", Line: "N", StopID: "N02S", Direction: ""},
-    },
-    "cta": {
-        {Provider: "cta", ProviderID: "cta-subway", Line: "Red", StopID: "40900", Direction: "N"},
-        {Provider: "cta", ProviderID: "cta-subway", Line: "Red", StopID: "40900", Direction: "S"},
-        {Provider: "cta", ProviderID: "cta-subway", Line: "Blue", StopID: "40380", Direction: "S"},
-        {Provider: "cta", ProviderID: "cta-subway", Line: "Blue", StopID: "40380", Direction: "N"},
-        {Provider: "cta", ProviderID: "cta-subway", Line: "Brn", StopID: "40730", Direction: "N"},
-        {Provider: "cta", ProviderID: "cta-subway", Line: "Brn", StopID: "40730", Direction: "S"},
-        {Provider: "cta", ProviderID: "cta-subway", Line: "G", StopID: "40280", Direction: "S"},
-        {Provider: "cta", ProviderID: "cta-subway", Line: "G", StopID: "40280", Direction: "N"},
-    },
-    "mbta": {
-        {Provider: "mbta", ProviderID: "mbta", Line: "Red", StopID: "place-pktrm", Direction: "1"},
-        {Provider: "mbta", ProviderID: "mbta", Line: "Red", StopID: "place-pktrm", Direction: "0"},
-        {Provider: "mbta", ProviderID: "mbta", Line: "Orange", StopID: "place-dwnxg", Direction: "0"},
-        {Provider: "mbta", ProviderID: "mbta", Line: "Orange", StopID: "place-dwnxg", Direction: "1"},
-        {Provider: "mbta", ProviderID: "mbta", Line: "Green-B", StopID: "place-kenmore", Direction: "0"},
-        {Provider: "mbta", ProviderID: "mbta", Line: "Green-B", StopID: "place-kenmore", Direction: "1"},
-    },
-    "septa": {
-        {Provider: "septa", ProviderID: "septa-rail", Line: "NHSL", StopID: "PENN_CENTER", Direction: "N"},
-        {Provider: "septa", ProviderID: "septa-rail", Line: "NHSL", StopID: "PENN_CENTER", Direction: "S"},
-        {Provider: "septa", ProviderID: "septa-rail", Line: "PAOLI", StopID: "30TH_STREET", Direction: "E"},
-        {Provider: "septa", ProviderID: "septa-rail", Line: "PAOLI", StopID: "30TH_STREET", Direction: "W"},
-    },
+// builtinStops is the curated catalogue used unless UseCatalogue replaces it.
+var builtinStops = []Entry{
+    {Provider: "mta", ProviderID: "mta-subway", Line: "A", StopID: "A19N", Direction: ""},
+    {Provider: "mta", ProviderID: "mta-subway", Line: "A", StopID: "A19S", Direction: ""},
+    {Provider: "mta", ProviderID: "mta-subway", Line: "L", StopID: "L03N", Direction: ""},
+    {Provider: "mta", ProviderID: "mta-subway", Line: "L", StopID: "L03S", Direction: ""},
+    {Provider: "mta", ProviderID: "mta-subway", Line: "1", StopID: "127N", Direction: ""},
+    {Provider: "mta", ProviderID: "mta-subway", Line: "1", StopID: "127S", Direction: ""},
+    {Provider: "mta", ProviderID: "mta-subway", Line: "N", StopID: "N02N", Direction: ""},
+    {Provider: "mta", ProviderID: "mta-subway", Line: "N", StopID: "N02S", Direction: ""},
+    {Provider: "cta", ProviderID: "cta-subway", Line: "Red", StopID: "40900", Direction: "N"},
+    {Provider: "cta", ProviderID: "cta-subway", Line: "Red", StopID: "40900", Direction: "S"},
+    {Provider: "cta", ProviderID: "cta-subway", Line: "Blue", StopID: "40380", Direction: "S"},
+    {Provider: "cta", ProviderID: "cta-subway", Line: "Blue", StopID: "40380", Direction: "N"},
+    {Provider: "cta", ProviderID: "cta-subway", Line: "Brn", StopID: "40730", Direction: "N"},
+    {Provider: "cta", ProviderID: "cta-subway", Line: "Brn", StopID: "40730", Direction: "S"},
+    {Provider: "cta", ProviderID: "cta-subway", Line: "G", StopID: "40280", Direction: "S"},
+    {Provider: "cta", ProviderID: "cta-subway", Line: "G", StopID: "40280", Direction: "N"},
+    {Provider: "mbta", ProviderID: "mbta", Line: "Red", StopID: "place-pktrm", Direction: "1"},
+    {Provider: "mbta", ProviderID: "mbta", Line: "Red", StopID: "place-pktrm", Direction: "0"},
+    {Provider: "mbta", ProviderID: "mbta", Line: "Orange", StopID: "place-dwnxg", Direction: "0"},
+    {Provider: "mbta", ProviderID: "mbta", Line: "Orange", StopID: "place-dwnxg", Direction: "1"},
+    {Provider: "mbta", ProviderID: "mbta", Line: "Green-B", StopID: "place-kenmore", Direction: "0"},
+    {Provider: "mbta", ProviderID: "mbta", Line: "Green-B", StopID: "place-kenmore", Direction: "1"},
+    {Provider: "septa", ProviderID: "septa-rail", Line: "NHSL", StopID: "PENN_CENTER", Direction: "N"},
+    {Provider: "septa", ProviderID: "septa-rail", Line: "NHSL", StopID: "PENN_CENTER", Direction: "S"},
+    {Provider: "septa", ProviderID: "septa-rail", Line: "PAOLI", StopID: "30TH_STREET", Direction: "E"},
+    {Provider: "septa", ProviderID: "septa-rail", Line: "PAOLI", StopID: "30TH_STREET", Direction: "W"},
 }
 
-// PickStop returns a random stop for the given provider key (e.g. "cta", "mta").
-func PickStop(provider string) (Stop, bool) {
-    stops, ok := stopsByProvider[provider]
-    if !ok || len(stops) == 0 {
+// catalogue holds the stops in use; byProvider indexes it by provider key.
+var (
+    catalogue  = builtinStops
+    byProvider = indexCatalogue(builtinStops)
+)
+
+func indexCatalogue(entries []Entry) map[string][]Entry {
+    m := make(map[string][]Entry)
+    for _, e := range entries {
+        m[e.Provider] = append(m[e.Provider], e)
+    }
+    return m
+}
+
+// PickStop returns a random stop for the given provider key (e.g. "cta",
+// "mta"), choosing each stop in proportion to its catalogue weight.
+func PickStop(provider string, rng *rand.Rand) (Stop, bool) {
+    stops := byProvider[provider]
+    if len(stops) == 0 {
         return Stop{}, false
     }
-    return stops[rand.Intn(len(stops))], true
+    total := 0.0
+    for _, e := range stops {
+        total += e.weight()
+    }
+    x := rng.Float64() * total
+    for _, e := range stops {
+        x -= e.weight()
+        if x < 0 {
+            return e.Stop(), true
+        }
+    }
+    return stops[len(stops)-1].Stop(), true
 }
 
-// ValidProviders returns the list of known provider keys.
+// ValidProviders returns the provider keys in the catalogue, sorted.
 func ValidProviders() []string {
-    keys := make([]string, 0, len(stopsByProvider))
-    for k := range stopsByProvider {
+    keys := make([]string, 0, len(byProvider))
+    for k := range byProvider {
         keys = append(keys, k)
     }
+    sort.Strings(keys)
     return keys
 }
 
-// AssignProviders returns a slice of provider keys of length n, distributed
-// according to the given percentage map (must sum to 100).
-func AssignProviders(n int, dist map[string]int) []string {
-    result := make([]string, 0, n)
-    // Build a weighted list
-    var weighted []string
-    for provider, pct := range dist {
-        count := (pct * n) / 100
-        for i := 0; i < count; i++ {
-            weighted = append(weighted, provider)
+// EqualDist splits 100 percent as evenly as possible across keys, giving
+// the remainder to the first ones.
+func EqualDist(keys []string) map[string]int {
+    result := make(map[string]int, len(keys))
+    if len(keys) == 0 {
+        return result
+    }
+    base := 100 / len(keys)
+    rem := 100 % len(keys)
+    for i, k := range keys {
+        if i < rem {
+            result[k] = base + 1
+        } else {
+            result[k] = base
         }
     }
-    // Fill any remainder due to integer division
-    for len(weighted) < n {
-        for provider := range dist {
-            weighted = append(weighted, provider)
-            if len(weighted) >= n {
-                break
-            }
+    return result
+}
+
+// Apportion splits n devices across providers in proportion to weights,
+// using the largest-remainder method: each provider gets the whole part of
+// its exact share, and the devices left over go to the largest fractional
+// parts, ties broken by provider key. Weights are typically percentages
+// summing to 100, or absolute counts summing to n, which come back as-is.
+// Providers with no weight are left out.
+func Apportion(n int, weights map[string]int) map[string]int {
+    keys := make([]string, 0, len(weights))
+    total := 0
+    for k, w := range weights {
+        if w > 0 {
+            keys = append(keys, k)
+            total += w
+        }
+    }
+    sort.Strings(keys)
+    counts := make(map[string]int, len(keys))
+    if total == 0 || n <= 0 {
+        return counts
+    }
+    rems := make(map[string]int, len(keys)) // remainder, in units of 1/total device
+    left := n
+    for _, k := range keys {
+        counts[k] = n * weights[k] / total
+        rems[k] = n * weights[k] % total
+        left -= counts[k]
+    }
+    sort.SliceStable(keys, func(i, j int) bool { return rems[keys[i]] > rems[keys[j]] })
+    for _, k := range keys[:left] {
+        counts[k]++
+    }
+    return counts
+}
+
+// PickProvider returns a random provider key from dist, choosing each in
+// proportion to its value.
+func PickProvider(dist map[string]int, rng *rand.Rand) (string, bool) {
+    keys := make([]string, 0, len(dist))
+    total := 0
+    for k, w := range dist {
+        if w > 0 {
+            keys = append(keys, k)
+            total += w
+        }
+    }
+    if total == 0 {
+        return "", false
+    }
+    sort.Strings(keys)
+    x := rng.Intn(total)
+    for _, k := range keys {
+        x -= dist[k]
+        if x < 0 {
+            return k, true
+        }
+    }
+    return keys[len(keys)-1], true
+}
+
+// AssignProviders returns a slice of provider keys of length n, apportioned
+// by the given weights (see Apportion) and shuffled with rng.
+func AssignProviders(n int, dist map[string]int, rng *rand.Rand) []string {
+    counts := Apportion(n, dist)
+    keys := make([]string, 0, len(counts))
+    for k := range counts {
+        keys = append(keys, k)
+    }
+    // Build the list in a fixed order so a seeded rng gives the same result.
+    sort.Strings(keys)
+    result := make([]string, 0, n)
+    for _, k := range keys {
+        for i := 0; i < counts[k]; i++ {
+            result = append(result, k)
         }
     }
-    // Shuffle and trim
-    rand.Shuffle(len(weighted), func(i, j int) { weighted[i], weighted[j] = weighted[j], weighted[i] })
-    result = append(result, weighted[:n]...)
+    rng.Shuffle(len(result), func(i, j int) { result[i], result[j] = result[j], result[i] })
     return result
 }
@@ -0,0 +1,77 @@
+package providers
+
+import (
+    "maps"
+    "math/rand"
+    "slices"
+    "testing"
+)
+
+func TestApportion(t *testing.T) {
+    tests := []struct {
+        name    string
+        n       int
+        weights map[string]int
+        want    map[string]int
+    }{
+        {"equal percentages, ties by key", 5, map[string]int{"cta": 25, "mta": 25, "mbta": 25, "septa": 25},
+            map[string]int{"cta": 2, "mbta": 1, "mta": 1, "septa": 1}},
+        {"exact percentages", 10, map[string]int{"cta": 30, "mta": 40, "mbta": 20, "septa": 10},
+            map[string]int{"cta": 3, "mta": 4, "mbta": 2, "septa": 1}},
+        {"largest remainder wins", 7, map[string]int{"cta": 30, "mta": 40, "mbta": 20, "septa": 10},
+            map[string]int{"cta": 2, "mta": 3, "mbta": 1, "septa": 1}},
+        {"counts come back as-is", 13, map[string]int{"cta": 10, "mta": 3},
+            map[string]int{"cta": 10, "mta": 3}},
+        {"counts scaled as weights", 26, map[string]int{"cta": 10, "mta": 3},
+            map[string]int{"cta": 20, "mta": 6}},
+        {"zero weight left out", 3, map[string]int{"cta": 100, "mta": 0},
+            map[string]int{"cta": 3}},
+        {"fewer devices than providers", 1, map[string]int{"cta": 50, "mta": 50},
+            map[string]int{"cta": 1, "mta": 0}},
+        {"no devices", 0, map[string]int{"cta": 100}, map[string]int{}},
+        {"no weights", 5, map[string]int{}, map[string]int{}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := Apportion(tt.n, tt.weights)
+            if !maps.Equal(got, tt.want) {
+                t.Errorf("Apportion(%d, %v) = %v, want %v", tt.n, tt.weights, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestAssignProviders(t *testing.T) {
+    tests := []struct {
+        name string
+        n    int
+        dist map[string]int
+        want map[string]int
+    }{
+        {"percentages", 5, map[string]int{"cta": 25, "mta": 25, "mbta": 25, "septa": 25},
+            map[string]int{"cta": 2, "mbta": 1, "mta": 1, "septa": 1}},
+        {"counts", 13, map[string]int{"cta": 10, "mta": 3}, map[string]int{"cta": 10, "mta": 3}},
+        {"none", 0, map[string]int{"cta": 100}, map[string]int{}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := AssignProviders(tt.n, tt.dist, rand.New(rand.NewSource(1)))
+            if len(got) != tt.n {
+                t.Fatalf("got %d assignments, want %d", len(got), tt.n)
+            }
+            counts := make(map[string]int)
+            for _, k := range got {
+                counts[k]++
+            }
+            for k, n := range tt.want {
+                if counts[k] != n {
+                    t.Errorf("%s: got %d devices, want %d (all: %v)", k, counts[k], n, counts)
+                }
+            }
+            again := AssignProviders(tt.n, tt.dist, rand.New(rand.NewSource(1)))
+            if !slices.Equal(got, again) {
+                t.Errorf("same seed gave %v, then %v", got, again)
+            }
+        })
+    }
+}
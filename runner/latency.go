@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LatencySummary summarises latency samples for one key, e.g. an HTTP
+// endpoint (method + normalised path) or a provider. Durations encode to JSON
+// as milliseconds, under names ending in _ms.
+type LatencySummary struct {
+	Name  string        `json:"name"`
+	Count int           `json:"count"`
+	P50   time.Duration `json:"-"`
+	P90   time.Duration `json:"-"`
+	P99   time.Duration `json:"-"`
+	Max   time.Duration `json:"-"`
+}
+
+func (s LatencySummary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name  string  `json:"name"`
+		Count int     `json:"count"`
+		P50   float64 `json:"p50_ms"`
+		P90   float64 `json:"p90_ms"`
+		P99   float64 `json:"p99_ms"`
+		Max   float64 `json:"max_ms"`
+	}{s.Name, s.Count, millis(s.P50), millis(s.P90), millis(s.P99), millis(s.Max)})
+}
+
+// millis converts d to fractional milliseconds for JSON output.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// latencyTracker collects raw latency samples per key. Load test runs
+// issue few enough requests that keeping every sample is cheaper than
+// getting bucket boundaries right.
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+}
+
+func (t *latencyTracker) record(key string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.samples == nil {
+		t.samples = make(map[string][]time.Duration)
+	}
+	t.samples[key] = append(t.samples[key], d)
+}
+
+// summaries returns one entry per key, sorted by key.
+func (t *latencyTracker) summaries() []LatencySummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]LatencySummary, 0, len(t.samples))
+	for key, samples := range t.samples {
+		sorted := append([]time.Duration(nil), samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		out = append(out, LatencySummary{
+			Name:  key,
+			Count: len(sorted),
+			P50:   percentile(sorted, 0.50),
+			P90:   percentile(sorted, 0.90),
+			P99:   percentile(sorted, 0.99),
+			Max:   sorted[len(sorted)-1],
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// overall returns percentile q across every key's samples and the sample count.
+func (t *latencyTracker) overall(q float64) (time.Duration, int) {
+	t.mu.Lock()
+	var all []time.Duration
+	for _, samples := range t.samples {
+		all = append(all, samples...)
+	}
+	t.mu.Unlock()
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	return percentile(all, q), len(all)
+}
+
+// percentile returns the nearest-rank percentile q (0..1) of sorted samples.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
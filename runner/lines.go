@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/commute-live/loadtest/providers"
+)
+
+// Lines sets how many stops each device configures, as real displays show
+// several lines. The zero value configures one stop per device.
+type Lines struct {
+	Min int // stops per device; each device draws its count from Min..Max
+	Max int // 0 means Min
+
+	// MixProviders lets a device's further stops come from any provider in
+	// the distribution rather than only its own.
+	MixProviders bool
+}
+
+// Validate checks that the line counts are consistent.
+func (l Lines) Validate() error {
+	if l.Min < 0 || l.Max < 0 {
+		return fmt.Errorf("lines must not be negative")
+	}
+	if l.Max > 0 && l.Max < l.Min {
+		return fmt.Errorf("lines: max %d is below min %d", l.Max, l.Min)
+	}
+	return nil
+}
+
+func (l Lines) bounds() (lo, hi int) {
+	lo = max(l.Min, 1)
+	return lo, max(l.Max, lo)
+}
+
+// pick chooses the stops for one device whose first stop comes from
+// provider. Further stops come from the same provider, or with MixProviders
+// from one drawn from dist. A device gets fewer stops than it drew if the
+// catalogue runs out of distinct ones.
+func (l Lines) pick(provider string, dist map[string]int, rng *rand.Rand) ([]providers.Stop, error) {
+	lo, hi := l.bounds()
+	n := lo
+	if hi > lo {
+		n += rng.Intn(hi - lo + 1)
+	}
+	stops := make([]providers.Stop, 0, n)
+	seen := make(map[providers.Stop]bool, n)
+	for tries := 0; len(stops) < n && tries < 20*n; tries++ {
+		key := provider
+		if l.MixProviders && len(stops) > 0 {
+			key, _ = providers.PickProvider(dist, rng)
+		}
+		stop, ok := providers.PickStop(key, rng)
+		if !ok {
+			return nil, fmt.Errorf("no stops configured for provider %q", key)
+		}
+		if !seen[stop] {
+			seen[stop] = true
+			stops = append(stops, stop)
+		}
+	}
+	return stops, nil
+}
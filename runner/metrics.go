@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/commute-live/loadtest/device"
+	"github.com/commute-live/loadtest/metrics"
+)
+
+// runMetrics are the Prometheus metrics of a run. Everything except the
+// device-state gauge is fed from collectLogs, so scrapes lag by up to a tick.
+type runMetrics struct {
+	registry     *metrics.Registry
+	stepDuration *metrics.HistogramVec
+	stepErrors   *metrics.CounterVec
+	stepRetries  *metrics.CounterVec
+	httpRequests *metrics.CounterVec
+	httpDuration *metrics.HistogramVec
+	mqttMessages *metrics.CounterVec
+	pushLatency  *metrics.HistogramVec
+	mqttConn     *metrics.CounterVec
+	badPayloads  *metrics.CounterVec
+}
+
+func newRunMetrics(r *Runner) *runMetrics {
+	reg := metrics.NewRegistry()
+	m := &runMetrics{
+		registry: reg,
+		stepDuration: reg.Histogram("loadtest_lifecycle_step_duration_seconds",
+			"Duration of each device lifecycle step.", metrics.DefBuckets, "step", "provider", "result"),
+		stepErrors: reg.Counter("loadtest_errors_total",
+			"Lifecycle step failures, counting every failed attempt.", "step", "provider"),
+		stepRetries: reg.Counter("loadtest_step_retries_total",
+			"Lifecycle step attempts beyond the first.", "step", "provider"),
+		httpRequests: reg.Counter("loadtest_http_requests_total",
+			"HTTP requests by endpoint and status (status 0 = transport error).", "method", "endpoint", "status", "provider"),
+		httpDuration: reg.Histogram("loadtest_http_request_duration_seconds",
+			"HTTP request latency.", metrics.DefBuckets, "method", "endpoint", "provider"),
+		mqttMessages: reg.Counter("loadtest_mqtt_messages_received_total",
+			"MQTT messages received on device command topics.", "provider"),
+		pushLatency: reg.Histogram("loadtest_mqtt_push_latency_seconds",
+			"End-to-end latency of MQTT pushes where it could be measured.", metrics.DefBuckets, "provider"),
+		badPayloads: reg.Counter("loadtest_mqtt_invalid_payloads_total",
+			"Commands messages that failed schema or stop validation.", "provider"),
+		mqttConn: reg.Counter("loadtest_mqtt_connection_events_total",
+			"MQTT connection events after the initial connect: lost, reconnecting (attempt) and reconnected.", "event", "provider"),
+	}
+	reg.GaugeFunc("loadtest_devices", "Devices by lifecycle state.", r.deviceStateSamples, "state", "provider")
+	return m
+}
+
+// MetricsHandler serves the run's metrics in Prometheus text format.
+func (r *Runner) MetricsHandler() http.Handler {
+	return r.metrics.registry.Handler()
+}
+
+// deviceStateSamples counts devices per state and provider, emitting zeroes
+// for every state so dashboards don't see series appear and vanish.
+func (r *Runner) deviceStateSamples() []metrics.Sample {
+	counts := make(map[string]map[device.State]int)
+	for _, d := range r.Devices {
+		p := d.Stop.Provider
+		if counts[p] == nil {
+			counts[p] = make(map[device.State]int)
+		}
+		counts[p][d.GetState()]++
+	}
+	provs := make([]string, 0, len(counts))
+	for p := range counts {
+		provs = append(provs, p)
+	}
+	sort.Strings(provs)
+
+	var out []metrics.Sample
+	for _, p := range provs {
+		// StateDone is the last state in the enum.
+		for s := device.StateInit; s <= device.StateDone; s++ {
+			out = append(out, metrics.Sample{
+				LabelValues: []string{s.String(), p},
+				Value:       float64(counts[p][s]),
+			})
+		}
+	}
+	return out
+}
+
+func (m *runMetrics) observeHTTP(e device.HTTPLogEntry, provider string) {
+	m.httpRequests.Inc(e.Method, e.Endpoint, strconv.Itoa(e.Status), provider)
+	m.httpDuration.Observe(e.Duration.Seconds(), e.Method, e.Endpoint, provider)
+}
+
+func (m *runMetrics) observeStep(t device.StepTiming, provider string) {
+	if t.Attempt > 1 {
+		m.stepRetries.Inc(t.Name, provider)
+	}
+	result := "ok"
+	if t.Err != "" {
+		result = "error"
+		m.stepErrors.Inc(t.Name, provider)
+	}
+	m.stepDuration.Observe(t.Duration.Seconds(), t.Name, provider, result)
+}
+
+func (m *runMetrics) observeConn(ev device.ConnEvent, provider string) {
+	m.mqttConn.Inc(string(ev.Type), provider)
+}
+
+func (m *runMetrics) observeMQTT(msg device.MQTTMessage, provider string) {
+	m.mqttMessages.Inc(provider)
+	if msg.Invalid != "" {
+		m.badPayloads.Inc(provider)
+	}
+	if msg.LatencySource != "" {
+		m.pushLatency.Observe(msg.Latency.Seconds(), provider)
+	}
+}
@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+)
+
+// Ramp describes how devices are launched over time. The zero value launches
+// every device at once.
+type Ramp struct {
+	Step     int           // devices added per Interval (stepped ramp)
+	Interval time.Duration // pause between steps
+	Over     time.Duration // spread launches linearly over this duration
+}
+
+// Enabled reports whether the ramp delays any device launch.
+func (r Ramp) Enabled() bool {
+	return r.Over > 0 || (r.Step > 0 && r.Interval > 0)
+}
+
+// Validate checks that the ramp settings are consistent.
+func (r Ramp) Validate() error {
+	if r.Step < 0 || r.Interval < 0 || r.Over < 0 {
+		return fmt.Errorf("ramp values must not be negative")
+	}
+	if r.Over > 0 && (r.Step > 0 || r.Interval > 0) {
+		return fmt.Errorf("ramp: use either a linear ramp duration or step/interval, not both")
+	}
+	if (r.Step > 0) != (r.Interval > 0) {
+		return fmt.Errorf("ramp: step and interval must be set together")
+	}
+	return nil
+}
+
+func (r Ramp) String() string {
+	switch {
+	case r.Over > 0:
+		return "linear " + r.Over.String()
+	case r.Step > 0 && r.Interval > 0:
+		return fmt.Sprintf("+%d every %s", r.Step, r.Interval)
+	default:
+		return "off"
+	}
+}
+
+// offsets returns the launch delay, relative to Start, of each of n devices.
+func (r Ramp) offsets(n int) []time.Duration {
+	out := make([]time.Duration, n)
+	switch {
+	case r.Over > 0:
+		for i := range out {
+			out[i] = r.Over * time.Duration(i) / time.Duration(n)
+		}
+	case r.Step > 0 && r.Interval > 0:
+		for i := range out {
+			out[i] = r.Interval * time.Duration(i/r.Step)
+		}
+	}
+	return out
+}
@@ -0,0 +1,195 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/commute-live/loadtest/device"
+)
+
+// Report is the machine-readable summary of a finished run.
+type Report struct {
+	RunID       string           `json:"runId"`
+	Seed        int64            `json:"seed"`
+	Manifest    string           `json:"manifest,omitempty"`
+	StartedAt   time.Time        `json:"startedAt"`
+	FinishedAt  time.Time        `json:"finishedAt"`
+	ServerURL   string           `json:"serverUrl"`
+	Providers   map[string]int   `json:"providers"`
+	Stats       StatsSnapshot    `json:"stats"`
+	HTTPLatency []LatencySummary `json:"httpLatency"`
+	PushLatency []LatencySummary `json:"pushLatency"`
+	Thresholds  []CheckResult    `json:"thresholds,omitempty"`
+	Passed      bool             `json:"passed"`
+	Security    *SecurityReport  `json:"security,omitempty"`
+	Devices     []DeviceReport   `json:"devices"`
+}
+
+// StatsSnapshot is a point-in-time copy of Stats suitable for encoding.
+type StatsSnapshot struct {
+	TotalDevices          int     `json:"totalDevices"`
+	Launched              int64   `json:"launchedDevices"`
+	ActiveDevices         int64   `json:"activeDevices"`
+	ErrorCount            int64   `json:"errorCount"`
+	MQTTTotal             int64   `json:"mqttTotal"`
+	Retries               int64   `json:"retries"`
+	MQTTDrops             int64   `json:"mqttDrops"`
+	MQTTReconnectAttempts int64   `json:"mqttReconnectAttempts"`
+	MQTTReconnects        int64   `json:"mqttReconnects"`
+	PayloadErrors         int64   `json:"payloadErrors"`
+	MsgsPerSec            float64 `json:"msgsPerSec"`
+	ElapsedSec            float64 `json:"elapsedSec"`
+
+	RetriesByStep map[string]int64 `json:"retriesByStep,omitempty"`
+}
+
+// DeviceReport is the final state of a single device.
+type DeviceReport struct {
+	DeviceID      string                `json:"deviceId"`
+	Email         string                `json:"email"`
+	Provider      string                `json:"provider"`
+	StopID        string                `json:"stopId"`
+	State         string                `json:"state"`
+	ErrorMsg      string                `json:"error,omitempty"`
+	MQTTCount     int                   `json:"mqttCount"`
+	PayloadErrors int                   `json:"payloadErrors,omitempty"`
+	HTTPLog       []device.HTTPLogEntry `json:"httpLog"`
+	ConnLog       []device.ConnEvent    `json:"mqttConnLog,omitempty"`
+}
+
+// Snapshot returns a consistent copy of the aggregate counters.
+func (s *Stats) Snapshot() StatsSnapshot {
+	return StatsSnapshot{
+		TotalDevices:          s.TotalDevices,
+		Launched:              s.Launched.Load(),
+		ActiveDevices:         s.ActiveDevices.Load(),
+		ErrorCount:            s.ErrorCount.Load(),
+		MQTTTotal:             s.MQTTTotal.Load(),
+		Retries:               s.Retries.Load(),
+		MQTTDrops:             s.MQTTDrops.Load(),
+		MQTTReconnectAttempts: s.MQTTReconnectAttempts.Load(),
+		MQTTReconnects:        s.MQTTReconnects.Load(),
+		PayloadErrors:         s.PayloadErrors.Load(),
+		RetriesByStep:         s.RetriesByStep(),
+		MsgsPerSec:            s.MsgsPerSec(),
+		ElapsedSec:            time.Since(s.StartedAt).Seconds(),
+	}
+}
+
+// Report builds the final run report from the current device states.
+func (r *Runner) Report() Report {
+	// Pick up what happened after the last tick, e.g. logout and EventDone
+	// during shutdown.
+	r.Settle()
+	rep := Report{
+		RunID:       r.RunID,
+		Seed:        r.Seed,
+		StartedAt:   r.Stats.StartedAt,
+		FinishedAt:  time.Now(),
+		ServerURL:   r.Cfg.ServerURL,
+		Providers:   r.ProviderCounts(),
+		Stats:       r.Stats.Snapshot(),
+		HTTPLatency: r.Stats.HTTPLatency(),
+		PushLatency: r.Stats.PushLatency(),
+		Thresholds:  r.CheckThresholds(),
+		Security:    r.SecurityReport(),
+		Devices:     make([]DeviceReport, 0, len(r.Devices)),
+	}
+	rep.Passed = Passed(rep.Thresholds) && (rep.Security == nil || rep.Security.Passed)
+	if r.Manifest != nil {
+		rep.Manifest = r.Manifest.Path()
+	}
+	for _, d := range r.Devices {
+		rep.Devices = append(rep.Devices, DeviceReport{
+			DeviceID:      d.DeviceID,
+			Email:         d.Email,
+			Provider:      d.Stop.Provider,
+			StopID:        d.Stop.StopID,
+			State:         d.GetState().String(),
+			ErrorMsg:      d.GetErrorMsg(),
+			MQTTCount:     d.GetMQTTCount(),
+			PayloadErrors: d.GetPayloadErrors(),
+			HTTPLog:       d.GetHTTPLog(),
+			ConnLog:       d.GetConnLog(),
+		})
+	}
+	return rep
+}
+
+// WriteReport encodes the final run report as indented JSON.
+func (r *Runner) WriteReport(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.Report())
+}
+
+// Finished reports whether every device has reached a terminal state.
+func (r *Runner) Finished() bool {
+	for _, d := range r.Devices {
+		switch d.GetState() {
+		case device.StateError, device.StateDone:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ProviderCounts returns the number of devices assigned to each provider.
+func (r *Runner) ProviderCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, d := range r.Devices {
+		counts[d.Stop.Provider]++
+	}
+	return counts
+}
+
+// WriteProviderTable writes the number and share of devices per provider.
+func (r *Runner) WriteProviderTable(w io.Writer) {
+	fmt.Fprintln(w, "\n--- Devices per Provider ---")
+	counts := r.ProviderCounts()
+	if len(counts) == 0 {
+		fmt.Fprintln(w, "(no devices)")
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tDEVICES\tSHARE")
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f%%\n", k, counts[k], float64(counts[k])*100/float64(len(r.Devices)))
+	}
+	tw.Flush()
+}
+
+// WriteLatencyTable writes the per-endpoint HTTP latency and per-provider
+// MQTT push latency percentiles as human-readable tables.
+func (r *Runner) WriteLatencyTable(w io.Writer) {
+	r.collectLogs()
+	fmt.Fprintln(w, "\n--- HTTP Latency ---")
+	writeLatencyRows(w, "ENDPOINT", r.Stats.HTTPLatency())
+	fmt.Fprintln(w, "\n--- MQTT Push Latency ---")
+	writeLatencyRows(w, "PROVIDER", r.Stats.PushLatency())
+}
+
+func writeLatencyRows(w io.Writer, heading string, lat []LatencySummary) {
+	if len(lat) == 0 {
+		fmt.Fprintln(w, "(no samples)")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tCOUNT\tP50\tP90\tP99\tMAX\n", heading)
+	for _, l := range lat {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", l.Name, l.Count,
+			l.P50.Round(time.Millisecond), l.P90.Round(time.Millisecond),
+			l.P99.Round(time.Millisecond), l.Max.Round(time.Millisecond))
+	}
+	tw.Flush()
+}
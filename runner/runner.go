@@ -1,7 +1,10 @@
 package runner
 
 import (
+	"crypto/tls"
 	"fmt"
+	"io"
+	"math/rand"
 	"os"
 	"os/signal"
 	"strings"
@@ -12,32 +15,72 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/commute-live/loadtest/device"
+	"github.com/commute-live/loadtest/manifest"
 	"github.com/commute-live/loadtest/providers"
+	"github.com/google/uuid"
 )
 
 // Config holds all runtime parameters for the load test.
 type Config struct {
-	ServerURL    string
-	SecretKey    string
-	MQTTHost     string
-	MQTTPort     int
-	MQTTUsername string
-	MQTTPassword string
-	Devices      int
-	Providers    map[string]int
-	Duration     time.Duration
+	ServerURL        string
+	SecretKey        string
+	MQTTHost         string
+	MQTTPort         int
+	MQTTUsername     string
+	MQTTPassword     string
+	MQTTTransport    string // device.TransportTCP (default), TransportSSL, TransportWS or TransportWSS
+	MQTTPath         string // websocket path for ws/wss
+	MQTTTLS          device.TLSOptions
+	MQTTAuth         string // device.MQTTAuthShared (default), MQTTAuthServer or MQTTAuthDerived
+	CheckIsolation   bool
+	Probes           int // devices that probe other devices' topics, see SecurityReport
+	ValidatePayloads bool
+	CaptureBodies    bool // keep HTTP headers and bodies in device logs
+	CaptureLimit     int  // bytes per captured body; 0 means device.DefaultCaptureLimit
+	Devices          int
+	Providers        map[string]int // percentages or counts, see providers.Apportion
+	Duration         time.Duration
+	Ramp             Ramp
+	Lines            Lines  // stops each new device configures
+	ManifestDir      string // where to write the run manifest; empty disables it
+	Thresholds       Thresholds
+	Timeouts         device.Timeouts
+	Retry            device.RetryPolicy
+	StepRetry        map[string]device.RetryPolicy // keyed by device.StepKeys
+
+	// Reuse lists already-registered identities to run instead of creating
+	// new ones. Devices is capped at len(Reuse), and Providers and Lines are
+	// ignored.
+	Reuse      []manifest.Device
+	SkipLink   bool // reused identities only
+	SkipConfig bool // reused identities only
+
+	// Seed makes provider assignment, stop selection and retry jitter
+	// reproducible; device identities are always random. Zero picks a
+	// random seed. Either way the seed used is in Runner.Seed.
+	Seed int64
 }
 
 // Stats holds aggregate counters shared with the TUI.
 type Stats struct {
-	TotalDevices  int
-	ActiveDevices atomic.Int64
-	ErrorCount    atomic.Int64
-	MQTTTotal     atomic.Int64
-	StartedAt     time.Time
-	mqttWindow    [5]int64
-	windowIdx     int
-	windowMu      sync.Mutex
+	TotalDevices          int
+	Launched              atomic.Int64
+	ActiveDevices         atomic.Int64
+	ErrorCount            atomic.Int64
+	MQTTTotal             atomic.Int64
+	Retries               atomic.Int64 // lifecycle step attempts beyond the first
+	MQTTDrops             atomic.Int64 // broker connections lost after the initial connect
+	MQTTReconnectAttempts atomic.Int64 // automatic reconnect attempts
+	MQTTReconnects        atomic.Int64 // successful reconnects
+	PayloadErrors         atomic.Int64 // commands messages that failed validation
+	StartedAt             time.Time
+	mqttWindow            [5]int64
+	windowIdx             int
+	windowMu              sync.Mutex
+	latency               latencyTracker
+	pushLatency           latencyTracker
+	retryMu               sync.Mutex
+	retriesByStep         map[string]int64
 }
 
 // MsgsPerSec returns the rolling 5-second average of MQTT msgs/sec.
@@ -51,23 +94,139 @@ func (s *Stats) MsgsPerSec() float64 {
 	return float64(sum) / 5.0
 }
 
+// AvgMsgsPerSec returns the MQTT message rate averaged over the run's
+// active time, from the first device becoming active until Shutdown (or
+// now), and that time. Unlike MsgsPerSec it does not hinge on the last few
+// seconds.
+func (r *Runner) AvgMsgsPerSec() (float64, time.Duration) {
+	var first time.Time
+	for _, d := range r.Devices {
+		if at := d.GetActiveAt(); !at.IsZero() && (first.IsZero() || at.Before(first)) {
+			first = at
+		}
+	}
+	if first.IsZero() {
+		return 0, 0
+	}
+	end := time.Now()
+	if ns := r.stoppedAt.Load(); ns != 0 {
+		end = time.Unix(0, ns)
+	}
+	over := end.Sub(first)
+	if over <= 0 {
+		return 0, 0
+	}
+	// Count from the devices: Stats.MQTTTotal lags by up to a tick.
+	return float64(r.mqttTotal()) / over.Seconds(), over
+}
+
+// RetriesByStep returns a copy of the retry counts per lifecycle step.
+func (s *Stats) RetriesByStep() map[string]int64 {
+	s.retryMu.Lock()
+	defer s.retryMu.Unlock()
+	cp := make(map[string]int64, len(s.retriesByStep))
+	for k, v := range s.retriesByStep {
+		cp[k] = v
+	}
+	return cp
+}
+
+func (s *Stats) recordRetry(step string) {
+	s.Retries.Add(1)
+	s.retryMu.Lock()
+	if s.retriesByStep == nil {
+		s.retriesByStep = make(map[string]int64)
+	}
+	s.retriesByStep[step]++
+	s.retryMu.Unlock()
+}
+
+// HTTPLatency returns per-endpoint latency percentiles observed so far.
+func (s *Stats) HTTPLatency() []LatencySummary {
+	return s.latency.summaries()
+}
+
+// PushLatency returns per-provider end-to-end MQTT push latency percentiles.
+func (s *Stats) PushLatency() []LatencySummary {
+	return s.pushLatency.summaries()
+}
+
 // TickMsg is sent to the TUI every second for stats refresh.
 type TickMsg time.Time
 
 // Runner orchestrates N mock devices.
 type Runner struct {
-	Cfg     Config
-	Devices []*device.MockDevice
-	Stats   *Stats
-	EventCh chan device.Event
-	StopCh  chan struct{}
+	RunID    string
+	Seed     int64            // the seed all run randomness derives from, see Config.Seed
+	Manifest *manifest.Writer // nil when Cfg.ManifestDir is empty
+	Cfg      Config
+	Devices  []*device.MockDevice
+	Stats    *Stats
+	EventCh  chan device.Event
+	StopCh   chan struct{}
+
+	stoppedAt atomic.Int64 // UnixNano when Shutdown was first called; 0 while running
+
+	logMu    sync.Mutex
+	httpSeen []int // per-device count of HTTP log entries already in Stats
+	mqttSeen []int // per-device count of MQTT messages already in Stats
+	stepSeen []int // per-device count of step timings already in Stats
+	connSeen []int // per-device count of MQTT connection events already in Stats
+
+	metrics *runMetrics
+
+	// ObserverErr is set when the isolation probe observer could not
+	// connect, so publish leaks go undetected.
+	ObserverErr  error
+	stopObserver func()
+	securityMu   sync.Mutex
+	publishLeaks []device.SecurityFinding
 }
 
 // New creates a Runner and initialises all mock devices.
 func New(cfg Config) (*Runner, error) {
-	providerAssignments := providers.AssignProviders(cfg.Devices, cfg.Providers)
+	if err := cfg.Ramp.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Lines.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := device.BrokerURL(cfg.MQTTTransport, cfg.MQTTHost, cfg.MQTTPort, cfg.MQTTPath); err != nil {
+		return nil, err
+	}
+	switch cfg.MQTTAuth {
+	case "", device.MQTTAuthShared, device.MQTTAuthServer, device.MQTTAuthDerived:
+	default:
+		return nil, fmt.Errorf("unknown MQTT auth mode %q (want shared, server or derived)", cfg.MQTTAuth)
+	}
+	var tlsConfig *tls.Config
+	if cfg.MQTTTransport == device.TransportSSL || cfg.MQTTTransport == device.TransportWSS {
+		c, err := cfg.MQTTTLS.Config()
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig = c
+	}
+	if cfg.Probes < 0 {
+		return nil, fmt.Errorf("probe devices must not be negative, got %d", cfg.Probes)
+	}
+	if cfg.CaptureLimit < 0 {
+		return nil, fmt.Errorf("capture limit must not be negative, got %d", cfg.CaptureLimit)
+	}
+	if len(cfg.Reuse) > 0 && (cfg.Devices <= 0 || cfg.Devices > len(cfg.Reuse)) {
+		cfg.Devices = len(cfg.Reuse)
+	}
+
+	seed := cfg.Seed
+	for seed == 0 {
+		seed = rand.Int63()
+	}
+	rng := rand.New(rand.NewSource(seed))
 
 	r := &Runner{
+		// Not seeded: every run, even a replay, gets its own manifest.
+		RunID:   uuid.New().String(),
+		Seed:    seed,
 		Cfg:     cfg,
 		EventCh: make(chan device.Event, cfg.Devices*4),
 		StopCh:  make(chan struct{}),
@@ -75,34 +234,135 @@ func New(cfg Config) (*Runner, error) {
 			TotalDevices: cfg.Devices,
 			StartedAt:    time.Now(),
 		},
+		httpSeen: make([]int, cfg.Devices),
+		mqttSeen: make([]int, cfg.Devices),
+		stepSeen: make([]int, cfg.Devices),
+		connSeen: make([]int, cfg.Devices),
+	}
+	r.metrics = newRunMetrics(r)
+
+	if cfg.ManifestDir != "" {
+		w, err := manifest.Create(manifest.Path(cfg.ManifestDir, r.RunID), r.RunID, cfg.ServerURL, r.Stats.StartedAt)
+		if err != nil {
+			return nil, err
+		}
+		r.Manifest = w
+	}
+
+	opts := device.Options{
+		ServerURL:        cfg.ServerURL,
+		SecretKey:        cfg.SecretKey,
+		MQTTHost:         cfg.MQTTHost,
+		MQTTPort:         cfg.MQTTPort,
+		MQTTUsername:     cfg.MQTTUsername,
+		MQTTPassword:     cfg.MQTTPassword,
+		MQTTTransport:    cfg.MQTTTransport,
+		MQTTPath:         cfg.MQTTPath,
+		MQTTTLS:          tlsConfig,
+		MQTTAuth:         cfg.MQTTAuth,
+		CheckIsolation:   cfg.CheckIsolation,
+		ValidatePayloads: cfg.ValidatePayloads,
+		CaptureBodies:    cfg.CaptureBodies,
+		CaptureLimit:     cfg.CaptureLimit,
+		SkipLink:         cfg.SkipLink,
+		SkipConfig:       cfg.SkipConfig,
+		Timeouts:         cfg.Timeouts,
+		Retry:            cfg.Retry,
+		StepRetry:        cfg.StepRetry,
+		Seed:             seed,
+	}
+
+	var providerAssignments []string
+	if len(cfg.Reuse) == 0 {
+		providerAssignments = providers.AssignProviders(cfg.Devices, cfg.Providers, rng)
 	}
 
 	for i := 0; i < cfg.Devices; i++ {
-		provKey := providerAssignments[i]
-		stop, ok := providers.PickStop(provKey)
-		if !ok {
-			return nil, fmt.Errorf("no stops configured for provider %q", provKey)
-		}
-		d := device.New(
-			cfg.ServerURL,
-			cfg.SecretKey,
-			cfg.MQTTHost,
-			cfg.MQTTUsername,
-			cfg.MQTTPassword,
-			cfg.MQTTPort,
-			stop,
-		)
+		var d *device.MockDevice
+		// Drawn in device order so each device's jitter replays however
+		// goroutines interleave.
+		opts := opts
+		opts.Seed = rng.Int63()
+		if len(cfg.Reuse) > 0 {
+			id := cfg.Reuse[i]
+			d = device.NewWithIdentity(opts, device.Identity{
+				DeviceID: id.DeviceID,
+				Email:    id.Email,
+				Password: id.Password,
+			}, id.Stops())
+		} else {
+			stops, err := cfg.Lines.pick(providerAssignments[i], cfg.Providers, rng)
+			if err != nil {
+				return nil, err
+			}
+			d = device.New(opts, device.NewIdentity(), stops)
+		}
 		r.Devices = append(r.Devices, d)
+		if r.Manifest != nil {
+			err := r.Manifest.AddDevice(manifest.Device{
+				DeviceID: d.DeviceID,
+				Email:    d.Email,
+				Password: d.Password,
+				Stop:     d.Stop,
+				Extra:    d.Stops[1:],
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if cfg.CheckIsolation && len(r.Devices) > 1 {
+		r.assignIsolationPeers()
+	}
+	if cfg.Probes > 0 && len(r.Devices) > 1 {
+		r.assignProbes(cfg.Probes)
+		r.watchProbes(opts)
 	}
 	return r, nil
 }
 
+// FinishManifest marks the run manifest as cleanly finished.
+func (r *Runner) FinishManifest() error {
+	if r.Manifest == nil {
+		return nil
+	}
+	return r.Manifest.Finish(time.Now())
+}
+
 // Start launches all device goroutines and the event processor. It does NOT block.
+// Devices are started according to Cfg.Ramp; launching stops early on Shutdown.
 // The caller must call Wait or Shutdown.
 func (r *Runner) Start(wg *sync.WaitGroup, program *tea.Program) {
 	go r.processEvents(program)
 
-	for _, d := range r.Devices {
+	// The launcher holds its own slot in wg so that per-device Adds made
+	// while ramping never race with the caller's Wait.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.launch(wg)
+	}()
+}
+
+func (r *Runner) launch(wg *sync.WaitGroup) {
+	offsets := r.Cfg.Ramp.offsets(len(r.Devices))
+	begin := time.Now()
+	for i, d := range r.Devices {
+		if wait := time.Until(begin.Add(offsets[i])); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-r.StopCh:
+				timer.Stop()
+				return
+			}
+		}
+		select {
+		case <-r.StopCh:
+			return
+		default:
+		}
+		r.Stats.Launched.Add(1)
 		wg.Add(1)
 		go func(d *device.MockDevice) {
 			defer wg.Done()
@@ -116,7 +376,11 @@ func (r *Runner) Shutdown() {
 	select {
 	case <-r.StopCh:
 	default:
+		r.stoppedAt.Store(time.Now().UnixNano())
 		close(r.StopCh)
+		if r.stopObserver != nil {
+			r.stopObserver()
+		}
 	}
 	for _, d := range r.Devices {
 		d.Shutdown()
@@ -150,17 +414,118 @@ func (r *Runner) WatchSignals(program *tea.Program, duration time.Duration) {
 
 // PrintCleanupSQL prints a SQL snippet to clean up loadtest records.
 func (r *Runner) PrintCleanupSQL() {
-	fmt.Println("\n--- Cleanup SQL ---")
-	fmt.Println("-- Run this on staging DB to remove all loadtest records:")
-	fmt.Printf("DELETE FROM users WHERE email LIKE 'loadtest-%%';\nDELETE FROM devices WHERE id LIKE 'loadtest-%%';")
+	r.WriteCleanupSQL(os.Stdout)
+}
+
+// WriteCleanupSQL writes SQL that deletes exactly this run's users and
+// devices, leaving other runs' records alone.
+func (r *Runner) WriteCleanupSQL(w io.Writer) {
+	fmt.Fprintln(w, "\n--- Cleanup SQL ---")
+	if len(r.Devices) == 0 {
+		fmt.Fprintln(w, "-- No records were created.")
+		return
+	}
 	emails := make([]string, 0, len(r.Devices))
+	ids := make([]string, 0, len(r.Devices))
 	for _, d := range r.Devices {
-		emails = append(emails, d.Email)
+		emails = append(emails, sqlString(d.Email))
+		ids = append(ids, sqlString(d.DeviceID))
 	}
-	fmt.Printf("-- Emails created:\n--   %s\n", strings.Join(emails, "\n--   "))
-	fmt.Printf("-- Device IDs created:\n")
+	fmt.Fprintf(w, "-- Run this on staging DB to remove the records of run %s:\n", r.RunID)
+	fmt.Fprintf(w, "DELETE FROM users WHERE email IN (\n  %s\n);\n", strings.Join(emails, ",\n  "))
+	fmt.Fprintf(w, "DELETE FROM devices WHERE id IN (\n  %s\n);\n", strings.Join(ids, ",\n  "))
+}
+
+// sqlString quotes s as a SQL string literal.
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// collectLogs feeds HTTP log entries, MQTT messages, step timings and MQTT
+// connection events recorded since the last call into the stats and metrics.
+func (r *Runner) collectLogs() {
+	r.logMu.Lock()
+	defer r.logMu.Unlock()
+	for i, d := range r.Devices {
+		entries := d.HTTPLogSince(r.httpSeen[i])
+		r.httpSeen[i] += len(entries)
+		for _, e := range entries {
+			r.Stats.latency.record(e.Method+" "+e.Endpoint, e.Duration)
+			r.metrics.observeHTTP(e, d.Stop.Provider)
+		}
+		msgs := d.MQTTMsgsSince(r.mqttSeen[i])
+		r.mqttSeen[i] += len(msgs)
+		for _, m := range msgs {
+			if m.LatencySource != "" {
+				r.Stats.pushLatency.record(d.Stop.Provider, m.Latency)
+			}
+			if m.Invalid != "" {
+				r.Stats.PayloadErrors.Add(1)
+			}
+			r.metrics.observeMQTT(m, d.Stop.Provider)
+		}
+		steps := d.StepLogSince(r.stepSeen[i])
+		r.stepSeen[i] += len(steps)
+		for _, t := range steps {
+			if t.Attempt > 1 {
+				r.Stats.recordRetry(t.Name)
+			}
+			r.metrics.observeStep(t, d.Stop.Provider)
+		}
+		events := d.ConnLogSince(r.connSeen[i])
+		r.connSeen[i] += len(events)
+		for _, ev := range events {
+			switch ev.Type {
+			case device.ConnLost:
+				r.Stats.MQTTDrops.Add(1)
+			case device.ConnReconnecting:
+				r.Stats.MQTTReconnectAttempts.Add(1)
+			case device.ConnReconnected:
+				r.Stats.MQTTReconnects.Add(1)
+			}
+			r.metrics.observeConn(ev, d.Stop.Provider)
+		}
+	}
+}
+
+// applyEvent updates the device counters for one lifecycle event.
+func (r *Runner) applyEvent(ev device.Event) {
+	switch ev.Type {
+	case device.EventActive:
+		r.Stats.ActiveDevices.Add(1)
+	case device.EventError:
+		r.Stats.ErrorCount.Add(1)
+	case device.EventDone:
+		active := r.Stats.ActiveDevices.Load()
+		if active > 0 {
+			r.Stats.ActiveDevices.Add(-1)
+		}
+	}
+}
+
+// mqttTotal counts the MQTT messages every device has received so far.
+func (r *Runner) mqttTotal() int64 {
+	var total int64
 	for _, d := range r.Devices {
-		fmt.Printf("--   %s\n", d.DeviceID)
+		total += int64(d.GetMQTTCount())
+	}
+	return total
+}
+
+// Settle folds in what processEvents has not seen yet: events still
+// queued, such as the EventDone of devices that stopped after StopCh
+// closed, the MQTT total and the logs. Call it once the devices have
+// stopped; Report does, so the final report matches their final state.
+func (r *Runner) Settle() {
+	for {
+		select {
+		case ev := <-r.EventCh:
+			r.applyEvent(ev)
+		default:
+			r.Stats.MQTTTotal.Store(r.mqttTotal())
+			r.collectLogs()
+			return
+		}
 	}
 }
 
@@ -175,25 +540,12 @@ func (r *Runner) processEvents(program *tea.Program) {
 			if !ok {
 				return
 			}
-			switch ev.Type {
-			case device.EventActive:
-				r.Stats.ActiveDevices.Add(1)
-			case device.EventError:
-				r.Stats.ErrorCount.Add(1)
-			case device.EventDone:
-				active := r.Stats.ActiveDevices.Load()
-				if active > 0 {
-					r.Stats.ActiveDevices.Add(-1)
-				}
-			}
+			r.applyEvent(ev)
 			if program != nil {
 				program.Send(ev)
 			}
 		case <-mqttTicker.C:
-			var total int64
-			for _, d := range r.Devices {
-				total += int64(d.GetMQTTCount())
-			}
+			total := r.mqttTotal()
 			r.Stats.MQTTTotal.Store(total)
 			delta := total - lastTotal
 			lastTotal = total
@@ -201,6 +553,7 @@ func (r *Runner) processEvents(program *tea.Program) {
 			r.Stats.mqttWindow[r.Stats.windowIdx%5] = delta
 			r.Stats.windowIdx++
 			r.Stats.windowMu.Unlock()
+			r.collectLogs()
 			if program != nil {
 				program.Send(TickMsg(time.Now()))
 			}
@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestSeedReproducible checks that two runners built with the same seed
+// assign the same providers, stops and device seeds, with fresh identities.
+func TestSeedReproducible(t *testing.T) {
+	build := func(seed int64) *Runner {
+		t.Helper()
+		r, err := New(Config{
+			MQTTHost:  "127.0.0.1",
+			MQTTPort:  1883,
+			Devices:   12,
+			Providers: map[string]int{"cta": 50, "mta": 30, "mbta": 20},
+			Lines:     Lines{Min: 1, Max: 3, MixProviders: true},
+			Seed:      seed,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	a, b := build(42), build(42)
+	if a.Seed != 42 || b.Seed != 42 {
+		t.Fatalf("seeds %d and %d, want 42", a.Seed, b.Seed)
+	}
+	for i := range a.Devices {
+		da, db := a.Devices[i], b.Devices[i]
+		if !slices.Equal(da.Stops, db.Stops) {
+			t.Errorf("device %d: stops %v, then %v", i, da.Stops, db.Stops)
+		}
+		if da.Seed != db.Seed {
+			t.Errorf("device %d: seed %d, then %d", i, da.Seed, db.Seed)
+		}
+		if da.DeviceID == db.DeviceID {
+			t.Errorf("device %d: identity %s reused", i, da.DeviceID)
+		}
+	}
+
+	c := build(43)
+	same := true
+	for i := range a.Devices {
+		same = same && slices.Equal(a.Devices[i].Stops, c.Devices[i].Stops) && a.Devices[i].Seed == c.Devices[i].Seed
+	}
+	if same {
+		t.Error("seeds 42 and 43 gave identical runs")
+	}
+}
@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/commute-live/loadtest/device"
+)
+
+// probePeers is how many other devices each isolation probe targets by ID,
+// on top of the wildcard subscriptions that cover every device.
+const probePeers = 3
+
+// maxFindingRows caps the findings listed in the summary table.
+const maxFindingRows = 20
+
+// SecurityReport is the outcome of the cross-device isolation checks and
+// probe.
+type SecurityReport struct {
+	Checks   int                      `json:"isolationChecks"` // devices that ran the CheckIsolation step
+	Probes   int                      `json:"probes"`
+	Observer string                   `json:"observer,omitempty"` // with probes: "ok", or why publish leaks could not be observed
+	Findings []device.SecurityFinding `json:"findings"`
+	Passed   bool                     `json:"passed"`
+}
+
+// assignIsolationPeers gives every device the next one in the fleet as the
+// target of its CheckIsolation step.
+func (r *Runner) assignIsolationPeers() {
+	for i, d := range r.Devices {
+		d.SetIsolationPeer(r.Devices[(i+1)%len(r.Devices)].DeviceID)
+	}
+}
+
+// assignProbes turns n devices, spread evenly over the fleet, into isolation
+// probes, each targeting the devices that follow it.
+func (r *Runner) assignProbes(n int) {
+	total := len(r.Devices)
+	if n > total {
+		n = total
+	}
+	for i := 0; i < n; i++ {
+		idx := i * total / n
+		var peers []string
+		for j := 1; j <= probePeers && j < total; j++ {
+			peers = append(peers, r.Devices[(idx+j)%total].DeviceID)
+		}
+		r.Devices[idx].SetProbePeers(peers)
+	}
+}
+
+// watchProbes starts the observer that catches probe publishes delivered to
+// other devices. Failing to connect it only narrows the probe, so the error
+// is kept for the report rather than returned.
+func (r *Runner) watchProbes(opts device.Options) {
+	stop, err := device.WatchProbes(opts, func(f device.SecurityFinding) {
+		r.securityMu.Lock()
+		r.publishLeaks = append(r.publishLeaks, f)
+		r.securityMu.Unlock()
+	})
+	if err != nil {
+		r.ObserverErr = err
+		return
+	}
+	r.stopObserver = stop
+}
+
+// SecurityReport collects the isolation check and probe findings, or
+// returns nil if the run had neither.
+func (r *Runner) SecurityReport() *SecurityReport {
+	rep := &SecurityReport{Findings: []device.SecurityFinding{}}
+	for _, d := range r.Devices {
+		if d.ChecksIsolation() {
+			rep.Checks++
+		}
+		if d.IsProbe() {
+			rep.Probes++
+		}
+		if d.ChecksIsolation() || d.IsProbe() {
+			rep.Findings = append(rep.Findings, d.GetFindings()...)
+		}
+	}
+	if rep.Checks == 0 && rep.Probes == 0 {
+		return nil
+	}
+	if rep.Probes > 0 {
+		rep.Observer = "ok"
+		if r.ObserverErr != nil {
+			rep.Observer = r.ObserverErr.Error()
+		}
+	}
+	r.securityMu.Lock()
+	rep.Findings = append(rep.Findings, r.publishLeaks...)
+	r.securityMu.Unlock()
+	sort.Slice(rep.Findings, func(i, j int) bool {
+		return rep.Findings[i].Timestamp.Before(rep.Findings[j].Timestamp)
+	})
+	rep.Passed = len(rep.Findings) == 0
+	return rep
+}
+
+// WriteSecurityTable writes the isolation probe outcome, listing every
+// finding as a security failure.
+func WriteSecurityTable(w io.Writer, rep *SecurityReport) {
+	if rep == nil {
+		return
+	}
+	fmt.Fprintln(w, "\n--- Isolation Probe ---")
+	if rep.Probes > 0 && rep.Observer != "ok" {
+		fmt.Fprintf(w, "(publish leaks not observed: %s)\n", rep.Observer)
+	}
+	if rep.Passed {
+		fmt.Fprintf(w, "PASS  %d check(s), %d probe(s), no cross-device access\n", rep.Checks, rep.Probes)
+		return
+	}
+	kinds := make(map[string]int)
+	for _, f := range rep.Findings {
+		kinds[f.Kind]++
+	}
+	fmt.Fprintf(w, "SECURITY FAILURE  %d finding(s) from %d check(s), %d probe(s): %d %s, %d %s, %d %s\n",
+		len(rep.Findings), rep.Checks, rep.Probes,
+		kinds[device.FindingSubscribeGranted], device.FindingSubscribeGranted,
+		kinds[device.FindingMessageLeak], device.FindingMessageLeak,
+		kinds[device.FindingPublishLeak], device.FindingPublishLeak)
+	for i, f := range rep.Findings {
+		if i == maxFindingRows {
+			fmt.Fprintf(w, "  ... %d more in the JSON report\n", len(rep.Findings)-i)
+			break
+		}
+		fmt.Fprintf(w, "  %s  %s\n", f.Timestamp.Format("15:04:05"), f)
+	}
+}
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/commute-live/loadtest/device"
+)
+
+func TestSecurityReportIsolationChecks(t *testing.T) {
+	r, err := New(Config{
+		MQTTHost:       "127.0.0.1",
+		MQTTPort:       1883,
+		Devices:        3,
+		Providers:      map[string]int{"cta": 100},
+		CheckIsolation: true,
+		Seed:           1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := r.SecurityReport()
+	if rep == nil || rep.Checks != 3 || !rep.Passed {
+		t.Fatalf("clean run: got %+v, want 3 passing checks", rep)
+	}
+
+	granted := device.SecurityFinding{
+		Timestamp: time.Now(),
+		DeviceID:  r.Devices[1].DeviceID,
+		Kind:      device.FindingSubscribeGranted,
+		Topic:     "/device/" + r.Devices[2].DeviceID + "/commands",
+	}
+	r.Devices[1].Findings = append(r.Devices[1].Findings, granted)
+	rep = r.SecurityReport()
+	if rep.Passed || len(rep.Findings) != 1 || rep.Findings[0] != granted {
+		t.Errorf("granted subscription: got %+v, want one finding and a failure", rep)
+	}
+}
+
+func TestSecurityReportNoChecks(t *testing.T) {
+	r, err := New(Config{
+		MQTTHost:       "127.0.0.1",
+		MQTTPort:       1883,
+		Devices:        1,
+		Providers:      map[string]int{"cta": 100},
+		CheckIsolation: true,
+		Seed:           1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep := r.SecurityReport(); rep != nil {
+		t.Errorf("a lone device has no peer to check, got %+v", rep)
+	}
+}
@@ -0,0 +1,120 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/commute-live/loadtest/device"
+)
+
+// Thresholds are the pass/fail assertions evaluated at the end of a run.
+// Nil fields are not checked.
+type Thresholds struct {
+	MaxErrorRate  *float64       // fraction of launched devices that failed, see device.Failed
+	MinActiveRate *float64       // fraction of launched devices that reached StateActive
+	MaxHTTPP95    *time.Duration // p95 over every HTTP request of the run
+	MinMsgsPerSec *float64       // Runner.AvgMsgsPerSec at the end of the run
+}
+
+// CheckResult is the outcome of one threshold.
+type CheckResult struct {
+	Name      string `json:"name"`
+	Threshold string `json:"threshold"`
+	Actual    string `json:"actual"`
+	Pass      bool   `json:"pass"`
+}
+
+// CheckThresholds evaluates Cfg.Thresholds against the current run state.
+func (r *Runner) CheckThresholds() []CheckResult {
+	t := r.Cfg.Thresholds
+	var launched, errored, reached int
+	for _, d := range r.Devices {
+		if d.GetState() == device.StateInit {
+			continue
+		}
+		launched++
+		if d.Failed() {
+			errored++
+		}
+		if d.ReachedActive() {
+			reached++
+		}
+	}
+	rate := func(n int) float64 {
+		if launched == 0 {
+			return 0
+		}
+		return float64(n) / float64(launched)
+	}
+
+	var out []CheckResult
+	if t.MaxErrorRate != nil {
+		actual := rate(errored)
+		out = append(out, CheckResult{
+			Name:      "error rate",
+			Threshold: fmt.Sprintf("<= %.1f%%", *t.MaxErrorRate*100),
+			Actual:    fmt.Sprintf("%.1f%% (%d/%d)", actual*100, errored, launched),
+			Pass:      actual <= *t.MaxErrorRate,
+		})
+	}
+	if t.MinActiveRate != nil {
+		actual := rate(reached)
+		out = append(out, CheckResult{
+			Name:      "devices active",
+			Threshold: fmt.Sprintf(">= %.1f%%", *t.MinActiveRate*100),
+			Actual:    fmt.Sprintf("%.1f%% (%d/%d)", actual*100, reached, launched),
+			// A run where nothing launched proves nothing.
+			Pass: launched > 0 && actual >= *t.MinActiveRate,
+		})
+	}
+	if t.MaxHTTPP95 != nil {
+		r.collectLogs()
+		p95, n := r.Stats.latency.overall(0.95)
+		out = append(out, CheckResult{
+			Name:      "http p95",
+			Threshold: "<= " + t.MaxHTTPP95.String(),
+			Actual:    fmt.Sprintf("%s (%d reqs)", p95.Round(time.Millisecond), n),
+			Pass:      p95 <= *t.MaxHTTPP95,
+		})
+	}
+	if t.MinMsgsPerSec != nil {
+		actual, over := r.AvgMsgsPerSec()
+		out = append(out, CheckResult{
+			Name:      "mqtt msgs/sec",
+			Threshold: fmt.Sprintf(">= %.1f", *t.MinMsgsPerSec),
+			Actual:    fmt.Sprintf("%.1f (avg over %s)", actual, over.Round(time.Second)),
+			Pass:      actual >= *t.MinMsgsPerSec,
+		})
+	}
+	return out
+}
+
+// Passed reports whether every check passed.
+func Passed(results []CheckResult) bool {
+	for _, c := range results {
+		if !c.Pass {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteThresholdTable writes the threshold results as a pass/fail table.
+func WriteThresholdTable(w io.Writer, results []CheckResult) {
+	if len(results) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\n--- Thresholds ---")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tTHRESHOLD\tACTUAL\tRESULT")
+	for _, c := range results {
+		result := "PASS"
+		if !c.Pass {
+			result = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Name, c.Threshold, c.Actual, result)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/commute-live/loadtest/device"
+)
+
+// thresholdRunner returns a runner of four launched devices: three reached
+// active and one failed, 100 MQTT messages over exactly 10s of active time,
+// and 20 HTTP requests taking 1ms to 20ms (p95 19ms).
+func thresholdRunner(t *testing.T, th Thresholds) *Runner {
+	t.Helper()
+	r, err := New(Config{
+		MQTTHost:   "127.0.0.1",
+		MQTTPort:   1883,
+		Devices:    4,
+		Providers:  map[string]int{"cta": 100},
+		Thresholds: th,
+		Seed:       1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Minute)
+	for i, d := range r.Devices {
+		d.State = device.StateDone
+		if i < 3 {
+			d.ActiveAt = start.Add(time.Duration(i) * time.Second)
+			d.MQTTCount = []int{50, 30, 20}[i]
+		} else {
+			d.State = device.StateError
+		}
+	}
+	for i := 1; i <= 20; i++ {
+		r.Devices[0].HTTPLog = append(r.Devices[0].HTTPLog, device.HTTPLogEntry{
+			Method: "GET", Endpoint: "/x", Duration: time.Duration(i) * time.Millisecond,
+		})
+	}
+	r.stoppedAt.Store(start.Add(10 * time.Second).UnixNano())
+	return r
+}
+
+func TestCheckThresholds(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	d := func(v time.Duration) *time.Duration { return &v }
+	tests := []struct {
+		name string
+		th   Thresholds
+		pass bool
+	}{
+		{"error rate at limit", Thresholds{MaxErrorRate: f(0.25)}, true},
+		{"error rate over limit", Thresholds{MaxErrorRate: f(0.24)}, false},
+		{"active rate at limit", Thresholds{MinActiveRate: f(0.75)}, true},
+		{"active rate under limit", Thresholds{MinActiveRate: f(0.76)}, false},
+		{"http p95 at limit", Thresholds{MaxHTTPP95: d(19 * time.Millisecond)}, true},
+		{"http p95 over limit", Thresholds{MaxHTTPP95: d(19*time.Millisecond - 1)}, false},
+		{"msgs/sec at limit", Thresholds{MinMsgsPerSec: f(10)}, true},
+		{"msgs/sec under limit", Thresholds{MinMsgsPerSec: f(10.1)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := thresholdRunner(t, tt.th).CheckThresholds()
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			if got := Passed(results); got != tt.pass {
+				t.Errorf("pass = %v, want %v (%+v)", got, tt.pass, results[0])
+			}
+		})
+	}
+}
+
+func TestCheckThresholdsNothingLaunched(t *testing.T) {
+	f := 0.0
+	r := thresholdRunner(t, Thresholds{MinActiveRate: &f})
+	for _, d := range r.Devices {
+		d.State = device.StateInit
+	}
+	if Passed(r.CheckThresholds()) {
+		t.Error("a run where nothing launched passed its active-rate check")
+	}
+}
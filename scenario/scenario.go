@@ -0,0 +1,351 @@
+// Package scenario loads load test configuration from a YAML or JSON file.
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is the file representation of a load test run. Zero values mean
+// "not set" so that defaults, env vars and flags can fill them in.
+type Scenario struct {
+	Server         Server         `yaml:"server"`
+	MQTT           MQTT           `yaml:"mqtt"`
+	Devices        int            `yaml:"devices"`
+	Providers      map[string]int `yaml:"providers"`      // percentages summing to 100
+	ProviderCounts map[string]int `yaml:"providerCounts"` // devices per provider, instead of Providers
+	Duration       Duration       `yaml:"duration"`
+	Ramp           Ramp           `yaml:"ramp"`
+	Lines          Lines          `yaml:"lines"`
+	Thresholds     Thresholds     `yaml:"thresholds"`
+	Timeouts       Timeouts       `yaml:"timeouts"`
+	Retry          Retry          `yaml:"retry"`
+	Stops          Stops          `yaml:"stops"`
+	Seed           int64          `yaml:"seed"` // 0 picks a random seed
+}
+
+// Stops names a stop catalogue file; see providers.LoadCatalogue.
+type Stops struct {
+	File string `yaml:"file"`
+	Mode string `yaml:"mode"` // merge (default) or replace
+}
+
+// Server holds the CommuteLive HTTP API settings.
+type Server struct {
+	URL       string `yaml:"url"`
+	SecretKey string `yaml:"secretKey"`
+
+	CaptureBodies bool `yaml:"captureBodies"` // keep redacted HTTP headers and bodies
+	CaptureLimit  int  `yaml:"captureLimit"`  // bytes per body
+}
+
+// MQTT holds the broker settings.
+type MQTT struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	Transport string  `yaml:"transport"` // tcp, ssl, ws or wss
+	Path      string  `yaml:"path"`      // websocket path
+	TLS       MQTTTLS `yaml:"tls"`
+
+	Auth           string `yaml:"auth"` // shared, server or derived
+	CheckIsolation bool   `yaml:"checkIsolation"`
+	ProbeDevices   int    `yaml:"probeDevices"`
+
+	ValidatePayloads bool `yaml:"validatePayloads"`
+}
+
+// MQTTTLS mirrors device.TLSOptions.
+type MQTTTLS struct {
+	CAFile             string `yaml:"caFile"`
+	CertFile           string `yaml:"certFile"`
+	KeyFile            string `yaml:"keyFile"`
+	ServerName         string `yaml:"serverName"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+// Ramp mirrors runner.Ramp.
+type Ramp struct {
+	Step     int      `yaml:"step"`
+	Interval Duration `yaml:"interval"`
+	Over     Duration `yaml:"over"`
+}
+
+// Lines mirrors runner.Lines.
+type Lines struct {
+	Min          int  `yaml:"min"`
+	Max          int  `yaml:"max"` // 0 means min
+	MixProviders bool `yaml:"mixProviders"`
+}
+
+// Thresholds mirrors runner.Thresholds; nil fields are not checked.
+type Thresholds struct {
+	MaxErrorRate  *float64  `yaml:"maxErrorRate"`
+	MinActiveRate *float64  `yaml:"minActiveRate"`
+	MaxHTTPP95    *Duration `yaml:"maxHttpP95"`
+	MinMsgsPerSec *float64  `yaml:"minMsgsPerSec"`
+}
+
+// Timeouts mirrors device.Timeouts; unset steps keep their defaults.
+type Timeouts struct {
+	Register      Duration `yaml:"register"`
+	Login         Duration `yaml:"login"`
+	Link          Duration `yaml:"link"`
+	SetConfig     Duration `yaml:"setConfig"`
+	GetConfig     Duration `yaml:"getConfig"`
+	MQTTCreds     Duration `yaml:"mqttCredentials"`
+	MQTTConnect   Duration `yaml:"mqttConnect"`
+	MQTTSubscribe Duration `yaml:"mqttSubscribe"`
+}
+
+// Retry mirrors device.RetryPolicy, with per-step overrides that inherit
+// any field they leave unset.
+type Retry struct {
+	RetryPolicy `yaml:",inline"`
+	Steps       RetrySteps `yaml:"steps"`
+}
+
+// RetryPolicy mirrors device.RetryPolicy. Jitter is a pointer so that an
+// explicit 0 can turn it off.
+type RetryPolicy struct {
+	Attempts   int      `yaml:"attempts"`
+	Backoff    Duration `yaml:"backoff"`
+	MaxBackoff Duration `yaml:"maxBackoff"`
+	Jitter     *float64 `yaml:"jitter"`
+	Statuses   []int    `yaml:"statuses"`
+}
+
+// RetrySteps holds per-step retry overrides, keyed like Timeouts.
+type RetrySteps struct {
+	Register      *RetryPolicy `yaml:"register"`
+	Login         *RetryPolicy `yaml:"login"`
+	Link          *RetryPolicy `yaml:"link"`
+	SetConfig     *RetryPolicy `yaml:"setConfig"`
+	GetConfig     *RetryPolicy `yaml:"getConfig"`
+	MQTTCreds     *RetryPolicy `yaml:"mqttCredentials"`
+	MQTTConnect   *RetryPolicy `yaml:"mqttConnect"`
+	MQTTSubscribe *RetryPolicy `yaml:"mqttSubscribe"`
+}
+
+// Duration is a time.Duration written as a Go duration string, e.g. "90s".
+type Duration time.Duration
+
+// UnmarshalYAML parses a duration string.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return fmt.Errorf("line %d: expected a duration string like \"30s\"", node.Line)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", node.Line, s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// FieldError is a validation error for a single field, named by its path in
+// the file, e.g. "ramp.interval".
+type FieldError struct {
+	Field string
+	Msg   string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Msg
+}
+
+// Load reads and validates a scenario file. JSON files are accepted as YAML.
+// Unknown fields are rejected so typos don't silently fall back to defaults.
+func Load(path string) (*Scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	var sc Scenario
+	if err := dec.Decode(&sc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := sc.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+// Validate checks field values and returns every problem found.
+func (s *Scenario) Validate() error {
+	var errs []error
+	add := func(field, format string, args ...any) {
+		errs = append(errs, &FieldError{Field: field, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Server.URL != "" && !strings.HasPrefix(s.Server.URL, "http://") && !strings.HasPrefix(s.Server.URL, "https://") {
+		add("server.url", "must start with http:// or https://")
+	}
+	if s.MQTT.Port < 0 || s.MQTT.Port > 65535 {
+		add("mqtt.port", "must be between 1 and 65535, got %d", s.MQTT.Port)
+	}
+	switch s.MQTT.Transport {
+	case "", "tcp", "ssl", "ws", "wss":
+	default:
+		add("mqtt.transport", "must be tcp, ssl, ws or wss, got %q", s.MQTT.Transport)
+	}
+	switch s.MQTT.Auth {
+	case "", "shared", "server", "derived":
+	default:
+		add("mqtt.auth", "must be shared, server or derived, got %q", s.MQTT.Auth)
+	}
+	if s.MQTT.ProbeDevices < 0 {
+		add("mqtt.probeDevices", "must not be negative, got %d", s.MQTT.ProbeDevices)
+	}
+	if (s.MQTT.TLS.CertFile == "") != (s.MQTT.TLS.KeyFile == "") {
+		add("mqtt.tls", "certFile and keyFile must be set together")
+	}
+	switch s.Stops.Mode {
+	case "", "merge", "replace":
+	default:
+		add("stops.mode", "must be merge or replace, got %q", s.Stops.Mode)
+	}
+	if s.Devices < 0 {
+		add("devices", "must not be negative, got %d", s.Devices)
+	}
+	// distTotal checks a provider map's values and returns their sum.
+	distTotal := func(field string, dist map[string]int) int {
+		keys := make([]string, 0, len(dist))
+		for k := range dist {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		total := 0
+		for _, k := range keys {
+			if dist[k] < 0 {
+				add(field+"."+k, "must not be negative, got %d", dist[k])
+			}
+			total += dist[k]
+		}
+		return total
+	}
+	if len(s.Providers) > 0 && len(s.ProviderCounts) > 0 {
+		add("providerCounts", "must not be set together with providers")
+	}
+	if len(s.Providers) > 0 {
+		if total := distTotal("providers", s.Providers); total != 100 {
+			add("providers", "percentages must sum to 100, got %d (use providerCounts for device counts)", total)
+		}
+	}
+	if len(s.ProviderCounts) > 0 {
+		switch total := distTotal("providerCounts", s.ProviderCounts); {
+		case total <= 0:
+			add("providerCounts", "must give at least one device")
+		case s.Devices > 0 && s.Devices != total:
+			add("providerCounts", "add up to %d devices, but devices is %d", total, s.Devices)
+		}
+	}
+	if s.Duration < 0 {
+		add("duration", "must not be negative")
+	}
+	if s.Lines.Min < 0 {
+		add("lines.min", "must not be negative, got %d", s.Lines.Min)
+	}
+	if s.Lines.Max < 0 {
+		add("lines.max", "must not be negative, got %d", s.Lines.Max)
+	} else if s.Lines.Max > 0 && s.Lines.Max < s.Lines.Min {
+		add("lines.max", "must not be below min %d, got %d", s.Lines.Min, s.Lines.Max)
+	}
+	if s.Ramp.Step < 0 {
+		add("ramp.step", "must not be negative, got %d", s.Ramp.Step)
+	}
+	if s.Ramp.Interval < 0 {
+		add("ramp.interval", "must not be negative")
+	}
+	if s.Ramp.Over < 0 {
+		add("ramp.over", "must not be negative")
+	}
+	if s.Ramp.Over > 0 && (s.Ramp.Step > 0 || s.Ramp.Interval > 0) {
+		add("ramp", "use either over or step/interval, not both")
+	}
+	if (s.Ramp.Step > 0) != (s.Ramp.Interval > 0) {
+		add("ramp", "step and interval must be set together")
+	}
+	checkFraction := func(field string, v *float64) {
+		if v != nil && (*v < 0 || *v > 1) {
+			add(field, "must be a fraction between 0 and 1, got %v", *v)
+		}
+	}
+	checkFraction("thresholds.maxErrorRate", s.Thresholds.MaxErrorRate)
+	checkFraction("thresholds.minActiveRate", s.Thresholds.MinActiveRate)
+	if s.Thresholds.MaxHTTPP95 != nil && *s.Thresholds.MaxHTTPP95 <= 0 {
+		add("thresholds.maxHttpP95", "must be positive")
+	}
+	if s.Thresholds.MinMsgsPerSec != nil && *s.Thresholds.MinMsgsPerSec < 0 {
+		add("thresholds.minMsgsPerSec", "must not be negative")
+	}
+	for _, t := range []struct {
+		field string
+		v     Duration
+	}{
+		{"timeouts.register", s.Timeouts.Register},
+		{"timeouts.login", s.Timeouts.Login},
+		{"timeouts.link", s.Timeouts.Link},
+		{"timeouts.setConfig", s.Timeouts.SetConfig},
+		{"timeouts.getConfig", s.Timeouts.GetConfig},
+		{"timeouts.mqttCredentials", s.Timeouts.MQTTCreds},
+		{"timeouts.mqttConnect", s.Timeouts.MQTTConnect},
+		{"timeouts.mqttSubscribe", s.Timeouts.MQTTSubscribe},
+	} {
+		if t.v < 0 {
+			add(t.field, "must not be negative")
+		}
+	}
+	checkRetry := func(field string, p *RetryPolicy) {
+		if p == nil {
+			return
+		}
+		if p.Attempts < 0 {
+			add(field+".attempts", "must not be negative, got %d", p.Attempts)
+		}
+		if p.Backoff < 0 {
+			add(field+".backoff", "must not be negative")
+		}
+		if p.MaxBackoff < 0 {
+			add(field+".maxBackoff", "must not be negative")
+		}
+		if p.Jitter != nil && (*p.Jitter < 0 || *p.Jitter > 1) {
+			add(field+".jitter", "must be a fraction between 0 and 1, got %v", *p.Jitter)
+		}
+		for _, st := range p.Statuses {
+			if st < 100 || st > 599 {
+				add(field+".statuses", "%d is not an HTTP status", st)
+			}
+		}
+	}
+	checkRetry("retry", &s.Retry.RetryPolicy)
+	for _, st := range []struct {
+		field string
+		p     *RetryPolicy
+	}{
+		{"retry.steps.register", s.Retry.Steps.Register},
+		{"retry.steps.login", s.Retry.Steps.Login},
+		{"retry.steps.link", s.Retry.Steps.Link},
+		{"retry.steps.setConfig", s.Retry.Steps.SetConfig},
+		{"retry.steps.getConfig", s.Retry.Steps.GetConfig},
+		{"retry.steps.mqttCredentials", s.Retry.Steps.MQTTCreds},
+		{"retry.steps.mqttConnect", s.Retry.Steps.MQTTConnect},
+		{"retry.steps.mqttSubscribe", s.Retry.Steps.MQTTSubscribe},
+	} {
+		checkRetry(st.field, st.p)
+	}
+	return errors.Join(errs...)
+}
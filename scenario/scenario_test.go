@@ -0,0 +1,107 @@
+package scenario
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func load(t *testing.T, body string) (*Scenario, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+// fieldErrors returns the Field of every FieldError Validate joined into err.
+func fieldErrors(err error) []string {
+	var fields []string
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		for _, e := range joined.Unwrap() {
+			var fe *FieldError
+			if errors.As(e, &fe) {
+				fields = append(fields, fe.Field)
+			}
+		}
+	}
+	return fields
+}
+
+func TestLoad(t *testing.T) {
+	sc, err := load(t, `
+devices: 10
+duration: 90s
+providers: {cta: 60, mta: 40}
+thresholds:
+  maxHttpP95: 250ms
+  minMsgsPerSec: 2
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Devices != 10 || time.Duration(sc.Duration) != 90*time.Second || sc.Providers["mta"] != 40 {
+		t.Errorf("got %+v", sc)
+	}
+	if p95 := sc.Thresholds.MaxHTTPP95; p95 == nil || time.Duration(*p95) != 250*time.Millisecond {
+		t.Errorf("maxHttpP95 = %v, want 250ms", p95)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string // substring of the error
+	}{
+		{"unknown field", "devices: 5\ndevicez: 6\n", "field devicez not found"},
+		{"unknown nested field", "mqtt:\n  hots: broker\n", "field hots not found"},
+		{"bad duration", "duration: 5 minutes\n", `line 1: invalid duration "5 minutes"`},
+		{"duration not a string", "ramp:\n  over: [1]\n", "line 2: expected a duration string"},
+		{"bad threshold duration", "thresholds:\n  maxHttpP95: fast\n", `line 2: invalid duration "fast"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(t, tt.body)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"valid thresholds", "thresholds:\n  maxErrorRate: 0\n  minActiveRate: 1\n", nil},
+		{"error rate above 1", "thresholds:\n  maxErrorRate: 1.5\n", []string{"thresholds.maxErrorRate"}},
+		{"active rate below 0", "thresholds:\n  minActiveRate: -0.1\n", []string{"thresholds.minActiveRate"}},
+		{"zero p95", "thresholds:\n  maxHttpP95: 0s\n", []string{"thresholds.maxHttpP95"}},
+		{"negative msgs/sec", "thresholds:\n  minMsgsPerSec: -1\n", []string{"thresholds.minMsgsPerSec"}},
+		{"every problem reported", "thresholds:\n  maxErrorRate: 2\n  minMsgsPerSec: -1\nduration: -1s\n",
+			[]string{"duration", "thresholds.maxErrorRate", "thresholds.minMsgsPerSec"}},
+		{"negative step timeout", "timeouts:\n  mqttConnect: -1s\n", []string{"timeouts.mqttConnect"}},
+		{"per-step retry", "retry:\n  steps:\n    login:\n      jitter: 2\n", []string{"retry.steps.login.jitter"}},
+		{"provider percentages", "providers: {cta: 50}\n", []string{"providers"}},
+		{"provider counts against devices", "devices: 5\nproviderCounts: {cta: 3}\n", []string{"providerCounts"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(t, tt.body)
+			got := fieldErrors(err)
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got fields %q (%v), want %q", got, err, tt.want)
+			}
+		})
+	}
+}
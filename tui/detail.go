@@ -31,6 +31,8 @@ func stateLabel(d *device.MockDevice) string {
     switch state {
     case device.StateActive:
         return activeStyle.Render("● ACTIVE")
+    case device.StateReconnecting:
+        return reconnectStyle.Render("↻ RECONNECTING")
     case device.StateError:
         return errorStyle.Render("✗ ERROR")
     case device.StateDone:
@@ -40,8 +42,10 @@ func stateLabel(d *device.MockDevice) string {
     }
 }
 
-// renderDetail renders the right panel showing details for the selected device.
-func renderDetail(d *device.MockDevice, width, height int) string {
+// renderDetail renders the right panel showing details for the selected
+// device, or just the expanded HTTP request or MQTT message if hv or mv
+// says so.
+func renderDetail(d *device.MockDevice, hv, mv *logView, width, height int) string {
     if height < 1 {
         height = 1
     }
@@ -51,6 +55,18 @@ func renderDetail(d *device.MockDevice, width, height int) string {
     if d == nil {
         return dimStyle.Render("  No device selected")
     }
+    if hv.expanded {
+        if log := d.GetHTTPLog(); len(log) > 0 {
+            i := hv.index(len(log))
+            return renderHTTPEntry(d, log[i], i+1, len(log), hv, width, height)
+        }
+    }
+    if mv.expanded {
+        if msgs := d.GetMQTTMsgs(); len(msgs) > 0 {
+            i := mv.index(len(msgs))
+            return renderMessage(d, msgs[i], i+1, len(msgs), mv, width, height)
+        }
+    }
 
     var lines []string
 
@@ -59,6 +75,12 @@ func renderDetail(d *device.MockDevice, width, height int) string {
         sectionStyle.Render("Device: ")+d.DeviceID,
         fmt.Sprintf("Provider: %-14s Stop: %-16s Dir: %s",
             d.Stop.ProviderID, d.Stop.StopID, d.Stop.Direction),
+    )
+    for _, s := range d.Stops[1:] {
+        lines = append(lines, dimStyle.Render(fmt.Sprintf("        + %-14s Stop: %-16s Dir: %s",
+            s.ProviderID, s.StopID, s.Direction)))
+    }
+    lines = append(lines,
         "Status: "+stateLabel(d),
     )
 
@@ -67,65 +89,60 @@ func renderDetail(d *device.MockDevice, width, height int) string {
     if errMsg != "" {
         lines = append(lines, httpErrStyle.Render("  Error: "+errMsg))
     }
+    if n := d.GetPayloadErrors(); n > 0 {
+        lines = append(lines, httpErrStyle.Render(fmt.Sprintf("  Invalid payloads: %d", n)))
+    }
 
-    // HTTP log section
-    lines = append(lines, "")
-    lines = append(lines, sectionStyle.Render("─── HTTP Log ───"))
+    // MQTT connection section, only once the connection has misbehaved
+    if connLog := d.GetConnLog(); len(connLog) > 0 {
+        lines = append(lines, "")
+        lines = append(lines, sectionStyle.Render("─── MQTT Connection ───"))
+        lines = append(lines, renderConnLog(connLog, width)...)
+    }
 
-    httpLog := d.GetHTTPLog()
-    maxHTTP := 10
-    httpStart := 0
-    if len(httpLog) > maxHTTP {
-        httpStart = len(httpLog) - maxHTTP
-    }
-    if len(httpLog) == 0 {
-        lines = append(lines, dimStyle.Render("  (no requests yet)"))
-    }
-    for _, entry := range httpLog[httpStart:] {
-        mark := httpOKStyle.Render("✓")
-        if !entry.OK {
-            mark = httpErrStyle.Render("✗")
+    // Isolation probe section, for probe devices only
+    if d.IsProbe() {
+        lines = append(lines, "")
+        lines = append(lines, sectionStyle.Render("─── Isolation Probe ───"))
+        findings := d.GetFindings()
+        if len(findings) == 0 {
+            lines = append(lines, httpOKStyle.Render("  no cross-device access so far"))
         }
-        line := fmt.Sprintf("%s  %-6s %-38s %3d %s",
-            dimStyle.Render(entry.Timestamp.Format("15:04:05")),
-            entry.Method,
-            truncate(entry.Path, 38),
-            entry.Status,
-            mark,
-        )
-        lines = append(lines, line)
-        if len(lines) >= height {
-            return strings.Join(lines[:height], "\n")
+        const maxFindings = 4
+        if len(findings) > maxFindings {
+            lines = append(lines, httpErrStyle.Render(fmt.Sprintf("  %d findings, latest:", len(findings))))
+            findings = findings[len(findings)-maxFindings:]
+        }
+        for _, f := range findings {
+            lines = append(lines, httpErrStyle.Render("  ✗ "+truncate(f.Kind+" "+f.Topic, width-4)))
         }
     }
 
-    // MQTT messages section
+    // HTTP log section
     lines = append(lines, "")
-    lines = append(lines, sectionStyle.Render("─── MQTT Messages ───"))
+    httpLog := d.GetHTTPLog()
+    if hv.focused && len(httpLog) > 0 {
+        lines = append(lines, sectionStyle.Render(fmt.Sprintf("─── HTTP Log [%d/%d] ───",
+            hv.index(len(httpLog))+1, len(httpLog))))
+    } else {
+        lines = append(lines, sectionStyle.Render("─── HTTP Log ───"))
+    }
+    const maxHTTP = 10
+    lines = append(lines, renderHTTPLog(httpLog, hv, maxHTTP)...)
+    if len(lines) >= height {
+        return strings.Join(lines[:height], "\n")
+    }
 
+    // MQTT messages section
+    lines = append(lines, "")
     mqttMsgs := d.GetMQTTMsgs()
-    remaining := height - len(lines)
-    if remaining < 1 {
-        remaining = 1
-    }
-    mqttStart := 0
-    if len(mqttMsgs) > remaining {
-        mqttStart = len(mqttMsgs) - remaining
-    }
-    if len(mqttMsgs) == 0 {
-        lines = append(lines, dimStyle.Render("  (waiting for MQTT messages...)"))
-    }
-    for _, msg := range mqttMsgs[mqttStart:] {
-        payload := truncate(msg.Payload, width-12)
-        line := fmt.Sprintf("%s  %s",
-            dimStyle.Render(msg.Timestamp.Format("15:04:05")),
-            mqttMsgStyle.Render(payload),
-        )
-        lines = append(lines, line)
-        if len(lines) >= height {
-            break
-        }
+    if mv.focused && len(mqttMsgs) > 0 {
+        lines = append(lines, sectionStyle.Render(fmt.Sprintf("─── MQTT Messages [%d/%d] ───",
+            mv.index(len(mqttMsgs))+1, len(mqttMsgs))))
+    } else {
+        lines = append(lines, sectionStyle.Render("─── MQTT Messages ───"))
     }
+    lines = append(lines, renderMQTTMsgs(mqttMsgs, mv, width, height-len(lines))...)
 
     // Trim to fit height (safe: height >= 1 guaranteed above)
     if len(lines) > height {
@@ -144,3 +161,38 @@ func truncate(s string, max int) string {
     }
     return s[:max-3] + "..."
 }
+
+// renderConnLog summarises a device's MQTT connection events and lists the
+// most recent ones.
+func renderConnLog(events []device.ConnEvent, width int) []string {
+    counts := make(map[device.ConnEventType]int)
+    for _, ev := range events {
+        counts[ev.Type]++
+    }
+    lines := []string{fmt.Sprintf("  Drops: %d  Reconnect attempts: %d  Reconnects: %d",
+        counts[device.ConnLost], counts[device.ConnReconnecting], counts[device.ConnReconnected])}
+
+    const maxConn = 4
+    if len(events) > maxConn {
+        events = events[len(events)-maxConn:]
+    }
+    for _, ev := range events {
+        style := reconnectStyle
+        switch ev.Type {
+        case device.ConnLost:
+            style = httpErrStyle
+        case device.ConnReconnected:
+            style = httpOKStyle
+        }
+        line := fmt.Sprintf("%s  %s", dimStyle.Render(ev.Timestamp.Format("15:04:05")), style.Render(fmt.Sprintf("%-12s", ev.Type)))
+        if ev.Reason != "" {
+            reasonWidth := width - 26
+            if reasonWidth < 0 {
+                reasonWidth = 0
+            }
+            line += " " + truncate(ev.Reason, reasonWidth)
+        }
+        lines = append(lines, line)
+    }
+    return lines
+}
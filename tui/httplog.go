@@ -0,0 +1,123 @@
+package tui
+
+import (
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+
+    "github.com/commute-live/loadtest/device"
+)
+
+// renderHTTPLog lists up to height requests, one per line. Unfocused it
+// shows the newest; focused it keeps the cursor in view and highlights it.
+func renderHTTPLog(entries []device.HTTPLogEntry, v *logView, height int) []string {
+    if len(entries) == 0 {
+        return []string{dimStyle.Render("  (no requests yet)")}
+    }
+    if height < 1 {
+        height = 1
+    }
+    cur := v.index(len(entries))
+
+    var lines []string
+    for i := v.window(len(entries), height, func(int) int { return 1 }); i < len(entries) && len(lines) < height; i++ {
+        entry := entries[i]
+        retry := ""
+        if entry.Attempt > 1 {
+            retry = fmt.Sprintf(" retry %d", entry.Attempt-1)
+        }
+        if v.focused && i == cur {
+            mark := "✓"
+            if !entry.OK {
+                mark = "✗"
+            }
+            lines = append(lines, cursorStyle.Render(fmt.Sprintf("%s  %-6s %-38s %3d %s%s",
+                entry.Timestamp.Format("15:04:05"), entry.Method, truncate(entry.Path, 38),
+                entry.Status, mark, retry)))
+            continue
+        }
+        mark := httpOKStyle.Render("✓")
+        if !entry.OK {
+            mark = httpErrStyle.Render("✗")
+        }
+        line := fmt.Sprintf("%s  %-6s %-38s %3d %s",
+            dimStyle.Render(entry.Timestamp.Format("15:04:05")),
+            entry.Method,
+            truncate(entry.Path, 38),
+            entry.Status,
+            mark,
+        )
+        line += dimStyle.Render(retry)
+        lines = append(lines, line)
+    }
+    return lines
+}
+
+// renderHTTPEntry fills the detail panel with one request: its summary, then
+// the captured headers and bodies if capture was on.
+func renderHTTPEntry(d *device.MockDevice, e device.HTTPLogEntry, n, total int, v *logView, width, height int) string {
+    status := httpOKStyle.Render(fmt.Sprintf("%d", e.Status))
+    if !e.OK {
+        status = httpErrStyle.Render(fmt.Sprintf("%d", e.Status))
+    }
+    head := []string{
+        sectionStyle.Render(fmt.Sprintf("─── HTTP Request %d/%d ───", n, total)),
+        "Device:   " + d.DeviceID,
+        "Request:  " + e.Method + " " + e.Path,
+        fmt.Sprintf("Status:   %s   Duration: %s", status, fmtMillis(e.Duration)),
+        "Sent:     " + e.Timestamp.Format("2006-01-02 15:04:05.000"),
+    }
+    if e.Attempt > 0 {
+        head = append(head, fmt.Sprintf("Attempt:  %d", e.Attempt))
+    }
+    head = append(head, "")
+
+    var body []string
+    c := e.Capture
+    if c == nil {
+        body = append(body, dimStyle.Render(fmt.Sprintf(
+            "(bodies not captured; --capture-bodies keeps them for the newest %d requests)",
+            device.CapturedEntries)))
+        return renderScrolled(head, body, v, height)
+    }
+    if c.Truncated {
+        body = append(body, dimStyle.Render("(bodies cut to the capture limit)"), "")
+    }
+    body = append(body, sectionStyle.Render("Request headers"))
+    body = append(body, renderHeaders(c.RequestHeaders)...)
+    body = append(body, "", sectionStyle.Render("Request body"))
+    body = append(body, renderBody(c.RequestBody)...)
+    if c.ResponseHeaders != nil || c.ResponseBody != "" {
+        body = append(body, "", sectionStyle.Render("Response headers"))
+        body = append(body, renderHeaders(c.ResponseHeaders)...)
+        body = append(body, "", sectionStyle.Render("Response body"))
+        body = append(body, renderBody(c.ResponseBody)...)
+    } else {
+        body = append(body, "", dimStyle.Render("(no response)"))
+    }
+    return renderScrolled(head, wrapLines(body, width), v, height)
+}
+
+func renderHeaders(h http.Header) []string {
+    if len(h) == 0 {
+        return []string{dimStyle.Render("  (none)")}
+    }
+    names := make([]string, 0, len(h))
+    for name := range h {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    var lines []string
+    for _, name := range names {
+        lines = append(lines, "  "+jsonKeyStyle.Render(name+":")+" "+strings.Join(h[name], ", "))
+    }
+    return lines
+}
+
+func renderBody(b string) []string {
+    if b == "" {
+        return []string{dimStyle.Render("  (empty)")}
+    }
+    return prettyJSON(b)
+}
@@ -0,0 +1,47 @@
+package tui
+
+import (
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/commute-live/loadtest/runner"
+)
+
+// renderLatency renders the right panel showing HTTP latency per endpoint and
+// MQTT push latency per provider.
+func renderLatency(stats *runner.Stats, width, height int) string {
+    if height < 1 {
+        height = 1
+    }
+
+    var lines []string
+    lines = append(lines, sectionStyle.Render("─── HTTP Latency ───"))
+    lines = appendLatencyRows(lines, "ENDPOINT", stats.HTTPLatency(), "  (no requests yet)")
+    lines = append(lines, "")
+    lines = append(lines, sectionStyle.Render("─── MQTT Push Latency ───"))
+    lines = appendLatencyRows(lines, "PROVIDER", stats.PushLatency(), "  (no measured pushes yet)")
+
+    if len(lines) > height {
+        lines = lines[:height]
+    }
+    return strings.Join(lines, "\n")
+}
+
+func appendLatencyRows(lines []string, heading string, lat []runner.LatencySummary, empty string) []string {
+    lines = append(lines, dimStyle.Render(fmt.Sprintf("%-34s %6s %7s %7s %7s %7s",
+        heading, "COUNT", "P50", "P90", "P99", "MAX")))
+    if len(lat) == 0 {
+        return append(lines, dimStyle.Render(empty))
+    }
+    for _, l := range lat {
+        lines = append(lines, fmt.Sprintf("%-34s %6d %7s %7s %7s %7s",
+            truncate(l.Name, 34), l.Count,
+            fmtMillis(l.P50), fmtMillis(l.P90), fmtMillis(l.P99), fmtMillis(l.Max)))
+    }
+    return lines
+}
+
+func fmtMillis(d time.Duration) string {
+    return fmt.Sprintf("%dms", d.Milliseconds())
+}
@@ -25,12 +25,17 @@ var (
 
     doneStyle = lipgloss.NewStyle().
         Foreground(lipgloss.Color("240"))
+
+    reconnectStyle = lipgloss.NewStyle().
+        Foreground(lipgloss.Color("214"))
 )
 
 func stateIndicator(d *device.MockDevice) string {
     switch d.GetState() {
     case device.StateActive:
         return activeStyle.Render("*")
+    case device.StateReconnecting:
+        return reconnectStyle.Render("↻")
     case device.StateError:
         return errorStyle.Render("!")
     case device.StateDone:
@@ -0,0 +1,80 @@
+package tui
+
+import (
+    "fmt"
+
+    "github.com/charmbracelet/x/ansi"
+    "github.com/commute-live/loadtest/device"
+)
+
+// renderMQTTMsgs lists up to height messages, one per line. Unfocused it
+// shows the newest; focused it keeps the cursor in view and highlights it.
+func renderMQTTMsgs(msgs []device.MQTTMessage, v *logView, width, height int) []string {
+    if len(msgs) == 0 {
+        return []string{dimStyle.Render("  (waiting for MQTT messages...)")}
+    }
+    if height < 1 {
+        height = 1
+    }
+    cur := v.index(len(msgs))
+    rows := func(i int) int {
+        if msgs[i].Invalid != "" && !(v.focused && i == cur) {
+            return 2 // the message and its reason
+        }
+        return 1
+    }
+
+    var lines []string
+    for i := v.window(len(msgs), height, rows); i < len(msgs) && len(lines) < height; i++ {
+        msg := msgs[i]
+        lat := ""
+        if msg.LatencySource != "" {
+            lat = fmtMillis(msg.Latency) + " "
+        }
+        payloadWidth := width - 12 - len(lat)
+        if payloadWidth < 0 {
+            payloadWidth = 0
+        }
+        payload := truncate(msg.Payload, payloadWidth)
+        if v.focused && i == cur {
+            lines = append(lines, cursorStyle.Render(fmt.Sprintf("%s  %s%s",
+                msg.Timestamp.Format("15:04:05"), lat, payload)))
+            continue
+        }
+        style := mqttMsgStyle
+        if msg.Invalid != "" {
+            style = httpErrStyle
+        }
+        line := fmt.Sprintf("%s  %s%s",
+            dimStyle.Render(msg.Timestamp.Format("15:04:05")),
+            dimStyle.Render(lat),
+            style.Render(payload),
+        )
+        lines = append(lines, line)
+        if msg.Invalid != "" && len(lines) < height {
+            lines = append(lines, httpErrStyle.Render("  ✗ "+truncate(msg.Invalid, width-4)))
+        }
+    }
+    return lines
+}
+
+// renderMessage fills the detail panel with one message: its metadata, then
+// the payload pretty-printed and highlighted if it is JSON.
+func renderMessage(d *device.MockDevice, msg device.MQTTMessage, n, total int, v *logView, width, height int) string {
+    head := []string{
+        sectionStyle.Render(fmt.Sprintf("─── MQTT Message %d/%d ───", n, total)),
+        "Device:   " + d.DeviceID,
+        "Topic:    " + msg.Topic,
+        fmt.Sprintf("QoS:      %d   Retained: %t", msg.QoS, msg.Retained),
+        "Received: " + msg.Timestamp.Format("2006-01-02 15:04:05.000"),
+    }
+    if msg.LatencySource != "" {
+        head = append(head, fmt.Sprintf("Latency:  %s (%s)", fmtMillis(msg.Latency), msg.LatencySource))
+    }
+    if msg.Invalid != "" {
+        head = append(head, httpErrStyle.Render(ansi.Hardwrap("Invalid:  "+msg.Invalid, width, true)))
+    }
+    head = append(head, "")
+
+    return renderScrolled(head, wrapLines(prettyJSON(msg.Payload), width), v, height)
+}
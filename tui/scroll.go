@@ -0,0 +1,204 @@
+package tui
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "strings"
+
+    "github.com/charmbracelet/lipgloss"
+    "github.com/charmbracelet/x/ansi"
+)
+
+var (
+    jsonKeyStyle = lipgloss.NewStyle().
+        Foreground(lipgloss.Color("75"))
+
+    jsonStringStyle = lipgloss.NewStyle().
+        Foreground(lipgloss.Color("114"))
+
+    jsonNumberStyle = lipgloss.NewStyle().
+        Foreground(lipgloss.Color("215"))
+
+    jsonLiteralStyle = lipgloss.NewStyle().
+        Foreground(lipgloss.Color("176"))
+
+    jsonPunctStyle = lipgloss.NewStyle().
+        Foreground(lipgloss.Color("245"))
+
+    cursorStyle = lipgloss.NewStyle().
+        Foreground(lipgloss.Color("15")).
+        Background(lipgloss.Color("238"))
+)
+
+// logView is the state of a focusable log section in the detail panel
+// (HTTP log or MQTT messages). With focused set the arrow keys move cursor
+// through the section's entries; with expanded set the entry under the
+// cursor fills the panel and the arrow keys scroll it.
+type logView struct {
+    focused  bool
+    cursor   int // index into the section's entries; -1 follows the newest
+    expanded bool
+    scroll   int
+}
+
+// newLogView returns an unfocused view following the newest entry.
+func newLogView() logView {
+    return logView{cursor: -1}
+}
+
+// index resolves the cursor against n entries, or -1 if there are none.
+func (v *logView) index(n int) int {
+    if n == 0 {
+        return -1
+    }
+    if v.cursor < 0 || v.cursor >= n {
+        return n - 1
+    }
+    return v.cursor
+}
+
+// move shifts the cursor by delta entries, or scrolls by delta lines when
+// expanded. Moving onto the newest entry follows new ones again.
+func (v *logView) move(delta, n int) {
+    if v.expanded {
+        v.scroll += delta // clamped when rendered
+        if v.scroll < 0 {
+            v.scroll = 0
+        }
+        return
+    }
+    i := v.index(n)
+    if i < 0 {
+        return
+    }
+    i += delta
+    if i < 0 {
+        i = 0
+    }
+    if i >= n-1 {
+        i = -1
+    }
+    v.cursor = i
+}
+
+// window picks the first entry to show so that entries from there to the
+// newest fill at most height rows, where rows(i) is the height of entry i.
+// If that leaves the cursor above the window, the window starts at the
+// cursor instead.
+func (v *logView) window(n, height int, rows func(i int) int) int {
+    start, used := n-1, rows(n-1)
+    for start > 0 && used+rows(start-1) <= height {
+        start--
+        used += rows(start)
+    }
+    if cur := v.index(n); v.focused && cur < start {
+        start = cur
+    }
+    return start
+}
+
+// renderScrolled fills height lines with head, then body from v.scroll on,
+// then a scroll hint. v.scroll is clamped to the body's length.
+func renderScrolled(head, body []string, v *logView, height int) string {
+    lines := append([]string{}, head...)
+    room := height - len(lines) - 1 // keep a line for the scroll hint
+    if room < 1 {
+        room = 1
+    }
+    maxScroll := len(body) - room
+    if maxScroll < 0 {
+        maxScroll = 0
+    }
+    if v.scroll > maxScroll {
+        v.scroll = maxScroll
+    }
+    end := v.scroll + room
+    if end > len(body) {
+        end = len(body)
+    }
+    lines = append(lines, body[v.scroll:end]...)
+    if maxScroll > 0 {
+        lines = append(lines, dimStyle.Render(fmt.Sprintf("  lines %d-%d of %d  ↑↓ scroll  esc back", v.scroll+1, end, len(body))))
+    } else {
+        lines = append(lines, dimStyle.Render("  esc back"))
+    }
+
+    if len(lines) > height {
+        lines = lines[:height]
+    }
+    return strings.Join(lines, "\n")
+}
+
+// wrapLines hard-wraps each line to width.
+func wrapLines(lines []string, width int) []string {
+    var out []string
+    for _, l := range lines {
+        out = append(out, strings.Split(ansi.Hardwrap(l, width, true), "\n")...)
+    }
+    return out
+}
+
+// prettyJSON indents and highlights payload. A payload that is not JSON is
+// returned as-is under a note saying so.
+func prettyJSON(payload string) []string {
+    var buf bytes.Buffer
+    if err := json.Indent(&buf, []byte(payload), "", "  "); err != nil {
+        return append([]string{dimStyle.Render("(not JSON: " + err.Error() + ")")},
+            strings.Split(payload, "\n")...)
+    }
+    lines := strings.Split(buf.String(), "\n")
+    for i, l := range lines {
+        lines[i] = highlightJSONLine(l)
+    }
+    return lines
+}
+
+// highlightJSONLine colours one line of json.Indent output. Indent puts at
+// most one key per line, so a string followed by ':' is the key.
+func highlightJSONLine(line string) string {
+    var b strings.Builder
+    for i := 0; i < len(line); {
+        c := line[i]
+        switch {
+        case c == '"':
+            j := i + 1
+            for j < len(line) && line[j] != '"' {
+                if line[j] == '\\' {
+                    j++
+                }
+                j++
+            }
+            if j < len(line) {
+                j++
+            }
+            style := jsonStringStyle
+            if strings.HasPrefix(line[j:], ":") {
+                style = jsonKeyStyle
+            }
+            b.WriteString(style.Render(line[i:j]))
+            i = j
+        case c == '-' || (c >= '0' && c <= '9'):
+            j := i + 1
+            for j < len(line) && strings.IndexByte("0123456789.eE+-", line[j]) >= 0 {
+                j++
+            }
+            b.WriteString(jsonNumberStyle.Render(line[i:j]))
+            i = j
+        case c >= 'a' && c <= 'z':
+            j := i + 1
+            for j < len(line) && line[j] >= 'a' && line[j] <= 'z' {
+                j++
+            }
+            b.WriteString(jsonLiteralStyle.Render(line[i:j]))
+            i = j
+        case strings.IndexByte("{}[],:", c) >= 0:
+            b.WriteString(jsonPunctStyle.Render(string(c)))
+            i++
+        default:
+            b.WriteByte(c)
+            i++
+        }
+    }
+    return b.String()
+}
@@ -3,16 +3,21 @@ package tui
 import (
     "fmt"
     "strings"
+    "time"
 
     tea "github.com/charmbracelet/bubbletea"
     "github.com/charmbracelet/lipgloss"
+    "github.com/commute-live/loadtest/providers"
+    "github.com/commute-live/loadtest/runner"
 )
 
 // SetupResult is returned by SetupModel when the user confirms.
 type SetupResult struct {
     Devices   int
     Duration  string       // empty = unlimited, otherwise e.g. "5m"
-    Providers map[string]int // distribution, sums to 100
+    Providers map[string]int // distribution, percentages or counts; see providers.Apportion
+    Counts    bool           // Providers are device counts, which fix Devices to their sum
+    Ramp      runner.Ramp    // launch schedule; zero = all at once
     Start     bool         // false = user cancelled
 }
 
@@ -21,17 +26,19 @@ type setupField int
 const (
     fieldDevices   setupField = 0
     fieldDuration  setupField = 1
-    fieldProviders setupField = 2
+    fieldRamp      setupField = 2
+    fieldProviders setupField = 3
+    fieldCount                = 4
 )
 
-var providerOrder = []string{"cta", "mta", "mbta", "septa"}
-
 var devicePresets = []int{1, 5, 10, 25, 50}
 
-var durationPresets = []struct {
+type durationPreset struct {
     label string
     value string
-}{
+}
+
+var durationPresets = []durationPreset{
     {"30s", "30s"},
     {"1m", "1m"},
     {"2m", "2m"},
@@ -40,6 +47,20 @@ var durationPresets = []struct {
     {"unlimited", ""},
 }
 
+type rampPreset struct {
+    label string
+    value runner.Ramp
+}
+
+var defaultRampPresets = []rampPreset{
+    {"off", runner.Ramp{}},
+    {"+1/5s", runner.Ramp{Step: 1, Interval: 5 * time.Second}},
+    {"+5/10s", runner.Ramp{Step: 5, Interval: 10 * time.Second}},
+    {"+10/30s", runner.Ramp{Step: 10, Interval: 30 * time.Second}},
+    {"linear 1m", runner.Ramp{Over: time.Minute}},
+    {"linear 5m", runner.Ramp{Over: 5 * time.Minute}},
+}
+
 // SetupModel is a bubbletea model for the pre-run configuration screen.
 type SetupModel struct {
     width            int
@@ -47,75 +68,138 @@ type SetupModel struct {
     serverURL        string
     field            setupField
     devIdx           int
+    devicePresets    []int
     durIdx           int
+    durationPresets  []durationPreset
+    rampIdx          int
+    rampPresets      []rampPreset
     providerIdx      int
+    providerOrder    []string
     enabledProviders map[string]bool
+    defaultDist      map[string]int
+    counts           bool // defaultDist holds device counts; see SetupResult.Counts
     Result           SetupResult
 }
 
-// NewSetupModel creates the setup model. CLI flag values are used as defaults.
-func NewSetupModel(serverURL string, defaultDevices int, defaultDuration string) *SetupModel {
-    devIdx := 1 // fallback: 5 devices
+// NewSetupModel creates the setup model. Values resolved from the scenario
+// file and CLI flags are used as defaults; any that match no preset are
+// offered as an extra option so the menu doesn't silently drop them.
+func NewSetupModel(serverURL string, defaults SetupResult) *SetupModel {
+    devicePresets := append([]int(nil), devicePresets...)
+    devIdx := -1
     for i, n := range devicePresets {
-        if n == defaultDevices {
+        if n == defaults.Devices {
             devIdx = i
             break
         }
     }
-    durIdx := 5 // fallback: unlimited
+    if devIdx < 0 {
+        if defaults.Devices > 0 {
+            devicePresets = append(devicePresets, defaults.Devices)
+            devIdx = len(devicePresets) - 1
+        } else {
+            devIdx = 1 // fallback: 5 devices
+        }
+    }
+
+    durationPresets := append([]durationPreset(nil), durationPresets...)
+    durIdx := -1
     for i, d := range durationPresets {
-        if d.value == defaultDuration {
+        if d.value == defaults.Duration {
             durIdx = i
             break
         }
     }
+    if durIdx < 0 {
+        durationPresets = append(durationPresets, durationPreset{defaults.Duration, defaults.Duration})
+        durIdx = len(durationPresets) - 1
+    }
+
+    rampPresets := append([]rampPreset(nil), defaultRampPresets...)
+    rampIdx := -1
+    for i, r := range rampPresets {
+        if r.value == defaults.Ramp {
+            rampIdx = i
+            break
+        }
+    }
+    if rampIdx < 0 {
+        rampPresets = append(rampPresets, rampPreset{defaults.Ramp.String(), defaults.Ramp})
+        rampIdx = len(rampPresets) - 1
+    }
+
+    // The provider row lists the stop catalogue's providers, including any
+    // loaded from a file.
+    providerOrder := providers.ValidProviders()
+    enabled := make(map[string]bool)
+    for _, p := range providerOrder {
+        enabled[p] = len(defaults.Providers) == 0 || defaults.Providers[p] > 0
+    }
+
     return &SetupModel{
-        serverURL: serverURL,
-        devIdx:    devIdx,
-        durIdx:    durIdx,
-        enabledProviders: map[string]bool{
-            "cta":   true,
-            "mta":   true,
-            "mbta":  true,
-            "septa": true,
-        },
+        serverURL:        serverURL,
+        devIdx:           devIdx,
+        devicePresets:    devicePresets,
+        durIdx:           durIdx,
+        durationPresets:  durationPresets,
+        rampIdx:          rampIdx,
+        rampPresets:      rampPresets,
+        providerOrder:    providerOrder,
+        enabledProviders: enabled,
+        defaultDist:      defaults.Providers,
+        counts:           defaults.Counts,
     }
 }
 
-// EnabledProviderDist returns an equal distribution across enabled providers,
-// summing exactly to 100.
+// devices returns the number of devices to run. With provider counts it is
+// their sum over the enabled providers, and the device row is locked.
+func (m *SetupModel) devices() int {
+    if !m.counts {
+        return m.devicePresets[m.devIdx]
+    }
+    total := 0
+    for _, n := range m.EnabledProviderDist() {
+        total += n
+    }
+    return total
+}
+
+// EnabledProviderDist returns the default distribution if the user left the
+// provider toggles as they were, otherwise an equal distribution across
+// enabled providers, summing exactly to 100 percent. With provider counts it
+// returns the counts of the enabled providers.
 func (m *SetupModel) EnabledProviderDist() map[string]int {
+    if m.counts {
+        dist := make(map[string]int)
+        for p, n := range m.defaultDist {
+            if m.enabledProviders[p] {
+                dist[p] = n
+            }
+        }
+        if len(dist) == 0 {
+            // Fallback: enable all
+            return m.defaultDist
+        }
+        return dist
+    }
     var enabled []string
-    for _, p := range providerOrder {
+    unchanged := len(m.defaultDist) > 0
+    for _, p := range m.providerOrder {
         if m.enabledProviders[p] {
             enabled = append(enabled, p)
         }
+        if m.enabledProviders[p] != (m.defaultDist[p] > 0) {
+            unchanged = false
+        }
+    }
+    if unchanged {
+        return m.defaultDist
     }
     if len(enabled) == 0 {
         // Fallback: enable all
-        result := make(map[string]int)
-        base := 100 / len(providerOrder)
-        rem := 100 % len(providerOrder)
-        for i, p := range providerOrder {
-            if i < rem {
-                result[p] = base + 1
-            } else {
-                result[p] = base
-            }
-        }
-        return result
-    }
-    result := make(map[string]int)
-    base := 100 / len(enabled)
-    rem := 100 % len(enabled)
-    for i, p := range enabled {
-        if i < rem {
-            result[p] = base + 1
-        } else {
-            result[p] = base
-        }
+        return providers.EqualDist(m.providerOrder)
     }
-    return result
+    return providers.EqualDist(enabled)
 }
 
 func (m *SetupModel) Init() tea.Cmd { return nil }
@@ -132,19 +216,23 @@ func (m *SetupModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
             m.Result = SetupResult{Start: false}
             return m, tea.Quit
         case "tab", "down", "j":
-            m.field = (m.field + 1) % 3
+            m.field = (m.field + 1) % fieldCount
         case "shift+tab", "up", "k":
-            m.field = (m.field + 2) % 3
+            m.field = (m.field + fieldCount - 1) % fieldCount
         case "left", "h":
             switch m.field {
             case fieldDevices:
-                if m.devIdx > 0 {
+                if m.devIdx > 0 && !m.counts {
                     m.devIdx--
                 }
             case fieldDuration:
                 if m.durIdx > 0 {
                     m.durIdx--
                 }
+            case fieldRamp:
+                if m.rampIdx > 0 {
+                    m.rampIdx--
+                }
             case fieldProviders:
                 if m.providerIdx > 0 {
                     m.providerIdx--
@@ -153,33 +241,37 @@ func (m *SetupModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
         case "right", "l":
             switch m.field {
             case fieldDevices:
-                if m.devIdx < len(devicePresets)-1 {
+                if m.devIdx < len(m.devicePresets)-1 && !m.counts {
                     m.devIdx++
                 }
             case fieldDuration:
-                if m.durIdx < len(durationPresets)-1 {
+                if m.durIdx < len(m.durationPresets)-1 {
                     m.durIdx++
                 }
+            case fieldRamp:
+                if m.rampIdx < len(m.rampPresets)-1 {
+                    m.rampIdx++
+                }
             case fieldProviders:
-                if m.providerIdx < len(providerOrder)-1 {
+                if m.providerIdx < len(m.providerOrder)-1 {
                     m.providerIdx++
                 }
             }
         case " ":
             if m.field == fieldProviders {
-                p := providerOrder[m.providerIdx]
-                m.enabledProviders[p] = !m.enabledProviders[p]
+                m.toggleProvider()
             }
         case "enter":
             if m.field == fieldProviders {
                 // Toggle current provider; don't submit
-                p := providerOrder[m.providerIdx]
-                m.enabledProviders[p] = !m.enabledProviders[p]
+                m.toggleProvider()
             } else {
                 m.Result = SetupResult{
-                    Devices:   devicePresets[m.devIdx],
-                    Duration:  durationPresets[m.durIdx].value,
+                    Devices:   m.devices(),
+                    Duration:  m.durationPresets[m.durIdx].value,
                     Providers: m.EnabledProviderDist(),
+                    Counts:    m.counts,
+                    Ramp:      m.rampPresets[m.rampIdx].value,
                     Start:     true,
                 }
                 return m, tea.Quit
@@ -189,6 +281,16 @@ func (m *SetupModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
     return m, nil
 }
 
+// toggleProvider enables or disables the provider under the cursor. With
+// provider counts, one without a count has no devices to give and stays off.
+func (m *SetupModel) toggleProvider() {
+    p := m.providerOrder[m.providerIdx]
+    if m.counts && m.defaultDist[p] == 0 {
+        return
+    }
+    m.enabledProviders[p] = !m.enabledProviders[p]
+}
+
 func (m *SetupModel) View() string {
     if m.width == 0 {
         return "Loading..."
@@ -256,12 +358,17 @@ func (m *SetupModel) View() string {
         devLbl = labelActiveSt.Render("▶ Devices  ")
     }
     var devOpts []string
-    for i, n := range devicePresets {
-        s := fmt.Sprintf("%d", n)
-        if i == m.devIdx {
-            devOpts = append(devOpts, chosenSt.Render(s))
-        } else {
-            devOpts = append(devOpts, optionSt.Render(s))
+    if m.counts {
+        devOpts = append(devOpts, chosenSt.Render(fmt.Sprintf("%d", m.devices())),
+            hintSt.Render("(sum of the provider counts)"))
+    } else {
+        for i, n := range m.devicePresets {
+            s := fmt.Sprintf("%d", n)
+            if i == m.devIdx {
+                devOpts = append(devOpts, chosenSt.Render(s))
+            } else {
+                devOpts = append(devOpts, optionSt.Render(s))
+            }
         }
     }
 
@@ -271,7 +378,7 @@ func (m *SetupModel) View() string {
         durLbl = labelActiveSt.Render("▶ Duration ")
     }
     var durOpts []string
-    for i, d := range durationPresets {
+    for i, d := range m.durationPresets {
         if i == m.durIdx {
             durOpts = append(durOpts, chosenSt.Render(d.label))
         } else {
@@ -279,16 +386,35 @@ func (m *SetupModel) View() string {
         }
     }
 
+    // ── ramp row ──
+    rampLbl := labelSt.Render("  Ramp     ")
+    if m.field == fieldRamp {
+        rampLbl = labelActiveSt.Render("▶ Ramp     ")
+    }
+    var rampOpts []string
+    for i, r := range m.rampPresets {
+        if i == m.rampIdx {
+            rampOpts = append(rampOpts, chosenSt.Render(r.label))
+        } else {
+            rampOpts = append(rampOpts, optionSt.Render(r.label))
+        }
+    }
+
     // ── providers row ──
     provLbl := labelSt.Render("  Providers")
     if m.field == fieldProviders {
         provLbl = labelActiveSt.Render("▶ Providers")
     }
+    // Enabled providers show how many of the devices they will get.
+    counts := providers.Apportion(m.devices(), m.EnabledProviderDist())
     var provOpts []string
-    for i, p := range providerOrder {
+    for i, p := range m.providerOrder {
         enabled := m.enabledProviders[p]
         isCursor := m.field == fieldProviders && i == m.providerIdx
         label := strings.ToUpper(p)
+        if enabled {
+            label += fmt.Sprintf(" %d", counts[p])
+        }
         if isCursor {
             if enabled {
                 provOpts = append(provOpts, cursorEnabledSt.Render("["+label+"]"))
@@ -311,6 +437,8 @@ func (m *SetupModel) View() string {
         "",
         durLbl + strings.Join(durOpts, " "),
         "",
+        rampLbl + strings.Join(rampOpts, " "),
+        "",
         provLbl + " " + strings.Join(provOpts, " "),
         "",
         hintSt.Render("  ↑↓/Tab  switch row     ←→  select/move     Space/Enter  toggle     q  quit"),
@@ -15,30 +15,48 @@ import (
 
 // Keybindings
 type keyMap struct {
-    Up      key.Binding
-    Down    key.Binding
-    Quit    key.Binding
-    Refresh key.Binding
-    Filter  key.Binding
-    Help    key.Binding
+    Up       key.Binding
+    Down     key.Binding
+    Quit     key.Binding
+    Refresh  key.Binding
+    Filter   key.Binding
+    Latency  key.Binding
+    Focus    key.Binding
+    Open     key.Binding
+    Back     key.Binding
+    PageUp   key.Binding
+    PageDown key.Binding
+    Help     key.Binding
 }
 
 var keys = keyMap{
-    Up:      key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "up")),
-    Down:    key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "down")),
-    Quit:    key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", "quit+cleanup")),
-    Refresh: key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "refresh device")),
-    Filter:  key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "filter errors")),
-    Help:    key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "help")),
+    Up:       key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "up")),
+    Down:     key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "down")),
+    Quit:     key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", "quit+cleanup")),
+    Refresh:  key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "refresh device")),
+    Filter:   key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "filter errors")),
+    Latency:  key.NewBinding(key.WithKeys("l"), key.WithHelp("l", "latency")),
+    Focus:    key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "focus HTTP/MQTT")),
+    Open:     key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "open entry")),
+    Back:     key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
+    PageUp:   key.NewBinding(key.WithKeys("pgup"), key.WithHelp("pgup", "page up")),
+    PageDown: key.NewBinding(key.WithKeys("pgdown"), key.WithHelp("pgdn", "page down")),
+    Help:     key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "help")),
 }
 
+// pageSize is how far pgup/pgdn move in a focused section.
+const pageSize = 10
+
 // Model is the root bubbletea model for the load test TUI.
 type Model struct {
     devices     []*device.MockDevice
     stats       *runner.Stats
     selected    int
     filterError bool
+    showLatency bool
     showHelp    bool
+    http        logView // HTTP log section of the detail panel
+    mqtt        logView // MQTT messages section of the detail panel
     width       int
     height      int
     startedAt   time.Time
@@ -49,6 +67,8 @@ func NewModel(devices []*device.MockDevice, stats *runner.Stats) *Model {
     return &Model{
         devices:   devices,
         stats:     stats,
+        http:      newLogView(),
+        mqtt:      newLogView(),
         startedAt: time.Now(),
     }
 }
@@ -69,6 +89,9 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
             m.showHelp = false
             return m, nil
         }
+        if (m.http.focused || m.mqtt.focused) && m.updateSection(msg) {
+            return m, nil
+        }
         switch {
         case key.Matches(msg, keys.Quit):
             return m, tea.Quit
@@ -81,6 +104,16 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
             if m.selected < len(visible)-1 {
                 m.selected++
             }
+        case key.Matches(msg, keys.Focus):
+            if m.selectedDevice() != nil {
+                m.http.focused = true
+                m.showLatency = false
+            }
+        case key.Matches(msg, keys.Open):
+            if m.selectedDevice() != nil {
+                m.mqtt = logView{focused: true, cursor: -1, expanded: true}
+                m.showLatency = false
+            }
         case key.Matches(msg, keys.Refresh):
             visible := m.visibleDevices()
             if m.selected < len(visible) {
@@ -90,6 +123,10 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
         case key.Matches(msg, keys.Filter):
             m.filterError = !m.filterError
             m.selected = 0
+            m.resetSections()
+        case key.Matches(msg, keys.Latency):
+            m.showLatency = !m.showLatency
+            m.resetSections()
         case key.Matches(msg, keys.Help):
             m.showHelp = !m.showHelp
         }
@@ -103,13 +140,73 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
     return m, nil
 }
 
+// updateSection handles a key while the HTTP log or MQTT section has focus
+// and reports whether it consumed it. Keys it leaves alone, like quit, fall
+// through.
+func (m *Model) updateSection(msg tea.KeyMsg) bool {
+    d := m.selectedDevice()
+    if d == nil {
+        m.resetSections()
+        return false
+    }
+    v, n := &m.http, len(d.GetHTTPLog())
+    if m.mqtt.focused {
+        v, n = &m.mqtt, d.GetMQTTCount()
+    }
+    switch {
+    case key.Matches(msg, keys.Back):
+        if v.expanded {
+            v.expanded = false
+        } else {
+            m.resetSections()
+        }
+    case key.Matches(msg, keys.Focus):
+        if v == &m.http {
+            m.resetSections()
+            m.mqtt.focused = true
+        } else {
+            m.resetSections()
+        }
+    case key.Matches(msg, keys.Open):
+        v.expanded = !v.expanded
+        v.scroll = 0
+    case key.Matches(msg, keys.Up):
+        v.move(-1, n)
+    case key.Matches(msg, keys.Down):
+        v.move(1, n)
+    case key.Matches(msg, keys.PageUp):
+        v.move(-pageSize, n)
+    case key.Matches(msg, keys.PageDown):
+        v.move(pageSize, n)
+    default:
+        return false
+    }
+    return true
+}
+
+// resetSections unfocuses the HTTP log and MQTT sections and sets them back
+// to following the newest entries.
+func (m *Model) resetSections() {
+    m.http = newLogView()
+    m.mqtt = newLogView()
+}
+
+// selectedDevice returns the device under the list cursor, or nil.
+func (m *Model) selectedDevice() *device.MockDevice {
+    visible := m.visibleDevices()
+    if m.selected < len(visible) {
+        return visible[m.selected]
+    }
+    return nil
+}
+
 func (m *Model) visibleDevices() []*device.MockDevice {
     if !m.filterError {
         return m.devices
     }
     var errored []*device.MockDevice
     for _, d := range m.devices {
-        if d.GetState() == device.StateError {
+        if d.Failed() {
             errored = append(errored, d)
         }
     }
@@ -155,7 +252,10 @@ func (m *Model) View() string {
 
     // Render panels — inner content only, no lipgloss padding.
     listContent := renderList(visible, m.selected, listWidth, bodyHeight)
-    detailContent := renderDetail(selectedDevice, detailWidth, bodyHeight)
+    detailContent := renderDetail(selectedDevice, &m.http, &m.mqtt, detailWidth, bodyHeight)
+    if m.showLatency {
+        detailContent = renderLatency(m.stats, detailWidth, bodyHeight)
+    }
 
     // Build body by joining lines side-by-side manually.
     // This avoids lipgloss JoinHorizontal padding surprises.
@@ -174,8 +274,15 @@ func (m *Model) View() string {
 
     header := headerStyle.Width(m.width).Render(m.statsBarView())
     footer := footerStyle.Width(m.width).Render(
-        "↑↓ navigate  q quit+cleanup  r refresh  e filter errors  ? help",
+        "↑↓ navigate  tab HTTP/MQTT  enter open  q quit+cleanup  r refresh  e filter errors  l latency  ? help",
     )
+    if m.http.expanded || m.mqtt.expanded {
+        footer = footerStyle.Width(m.width).Render("↑↓/pgup/pgdn scroll  enter/esc close  q quit+cleanup")
+    } else if m.http.focused {
+        footer = footerStyle.Width(m.width).Render("↑↓/pgup/pgdn select request  enter open  tab MQTT  esc devices  q quit+cleanup")
+    } else if m.mqtt.focused {
+        footer = footerStyle.Width(m.width).Render("↑↓/pgup/pgdn select message  enter open  tab/esc devices  q quit+cleanup")
+    }
 
     return header + "\n" + body + "\n" + footer
 }
@@ -192,12 +299,16 @@ func (m *Model) statsBarView() string {
     msgsPerSec := m.stats.MsgsPerSec()
 
     return fmt.Sprintf(
-        "Devices: %d  Active: %d  MQTT msgs: %s  %.1f/s  Errors: %d  Elapsed: %02d:%02d:%02d",
+        "Devices: %d/%d  Active: %d  MQTT msgs: %s  %.1f/s  Errors: %d  Retries: %d  Drops: %d  Bad payloads: %d  Elapsed: %02d:%02d:%02d",
+        m.stats.Launched.Load(),
         m.stats.TotalDevices,
         active,
         formatNumber(mqttTotal),
         msgsPerSec,
         errors,
+        m.stats.Retries.Load(),
+        m.stats.MQTTDrops.Load(),
+        m.stats.PayloadErrors.Load(),
         h, min, sec,
     )
 }
@@ -210,6 +321,11 @@ func (m *Model) helpView() string {
   q           Quit + trigger cleanup
   r           Force refresh selected device
   e           Toggle filter: errored devices only
+  l           Toggle HTTP / MQTT push latency panel
+  tab         Focus the HTTP log, then MQTT messages, then devices
+  enter       Open the request or message under the cursor
+  pgup/pgdn   Move or scroll a page in the focused section
+  esc         Close the entry / leave the section
   ?           Toggle this help overlay
 
   Press any key to close.`